@@ -0,0 +1,457 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/ppvan/nem/extractor"
+	"github.com/urfave/cli/v3"
+)
+
+type screen int
+
+const (
+	screenQuery screen = iota
+	screenResults
+	screenEpisodes
+)
+
+// previewDelay is how long the cursor must rest on a result before its
+// details are fetched, so scrolling through the list stays responsive.
+const previewDelay = 300 * time.Millisecond
+
+type previewResult struct {
+	id      int
+	details *extractor.AnimeDetail
+	err     error
+}
+
+// browser is the interactive search → pick → download flow.
+type browser struct {
+	ext  *extractor.AniVietSubExtractor
+	term *terminal
+	keys <-chan key
+
+	screen screen
+	query  string
+	status string
+
+	results        []extractor.SimpleAnime
+	cursor, offset int
+
+	details  map[int]*extractor.AnimeDetail
+	failures map[int]error
+	loading  map[int]bool
+	previews chan previewResult
+	debounce *time.Timer
+
+	anime              *extractor.AnimeDetail
+	selected           map[int]bool
+	epCursor, epOffset int
+}
+
+var (
+	highlight = color.New(color.ReverseVideo).SprintFunc()
+	dim       = color.New(color.Faint).SprintFunc()
+	bold      = color.New(color.Bold).SprintFunc()
+)
+
+func browseAction(ctx context.Context, cmd *cli.Command) error {
+	query := strings.Join(cmd.Args().Slice(), " ")
+	if cmd.Root() == cmd && query != "" {
+		return fmt.Errorf("unknown command %q", cmd.Args().First())
+	}
+
+	output := cmd.String("output")
+	if output == "" {
+		output = "."
+	}
+	if err := checkOutputDir(output); err != nil {
+		return err
+	}
+
+	opts := []extractor.Option{}
+	if name := cmd.String("strategy"); name != "" {
+		strategy, err := extractor.ParseStrategy(name)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, extractor.WithStrategy(strategy))
+	}
+
+	downloadOpts, err := newDownloadOptions(cmd, output)
+	if err != nil {
+		return err
+	}
+
+	ext, err := newExtractor(cmd, opts...)
+	if err != nil {
+		return err
+	}
+
+	t, err := openTerminal()
+	if err != nil {
+		return err
+	}
+
+	b := &browser{
+		ext:      ext,
+		term:     t,
+		keys:     readKeys(),
+		query:    query,
+		details:  make(map[int]*extractor.AnimeDetail),
+		failures: make(map[int]error),
+		loading:  make(map[int]bool),
+		previews: make(chan previewResult, 4),
+		debounce: time.NewTimer(time.Hour),
+	}
+	b.debounce.Stop()
+	if query != "" {
+		b.search()
+	}
+
+	done, err := b.run(ctx)
+	t.close()
+
+	if err != nil {
+		return err
+	}
+	if !done || b.anime == nil {
+		return nil
+	}
+
+	var episodes []extractor.Episode
+	for i, episode := range b.anime.Episodes {
+		if b.selected[i] {
+			episodes = append(episodes, episode)
+		}
+	}
+	return downloadEpisodes(ctx, ext, b.anime, episodes, downloadOpts)
+}
+
+// run processes input until the user confirms a selection (true) or quits.
+// Ctrl-C, which raw mode delivers as a key instead of a signal, and a
+// cancelled ctx end it with context.Canceled, so nem exits as interrupted.
+func (b *browser) run(ctx context.Context) (bool, error) {
+	for {
+		b.render()
+
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case k, ok := <-b.keys:
+			if !ok {
+				return false, nil
+			}
+			if k.kind == keyCtrlC {
+				return false, context.Canceled
+			}
+			if done, quit := b.handle(k); done || quit {
+				return done, nil
+			}
+		case p := <-b.previews:
+			delete(b.loading, p.id)
+			if p.err != nil {
+				b.failures[p.id] = p.err
+			} else {
+				b.details[p.id] = p.details
+			}
+		case <-b.debounce.C:
+			b.fetchPreview()
+		}
+	}
+}
+
+func (b *browser) handle(k key) (done bool, quit bool) {
+	switch b.screen {
+	case screenQuery:
+		switch k.kind {
+		case keyRune:
+			b.query += string(k.r)
+		case keyBackspace:
+			if r := []rune(b.query); len(r) > 0 {
+				b.query = string(r[:len(r)-1])
+			}
+		case keyEnter:
+			b.search()
+		case keyEsc:
+			return false, true
+		}
+
+	case screenResults:
+		switch {
+		case k.kind == keyUp || k.kind == keyRune && k.r == 'k':
+			b.moveCursor(-1)
+		case k.kind == keyDown || k.kind == keyRune && k.r == 'j':
+			b.moveCursor(1)
+		case k.kind == keyPageUp:
+			b.moveCursor(-b.listHeight())
+		case k.kind == keyPageDown:
+			b.moveCursor(b.listHeight())
+		case k.kind == keyEnter || k.kind == keyRight:
+			b.openEpisodes()
+		case k.kind == keyEsc || k.kind == keyRune && k.r == '/':
+			b.screen = screenQuery
+			b.status = ""
+		case k.kind == keyRune && k.r == 'q':
+			return false, true
+		}
+
+	case screenEpisodes:
+		count := len(b.anime.Episodes)
+		switch {
+		case k.kind == keyUp || k.kind == keyRune && k.r == 'k':
+			b.epCursor = max(b.epCursor-1, 0)
+		case k.kind == keyDown || k.kind == keyRune && k.r == 'j':
+			b.epCursor = min(b.epCursor+1, count-1)
+		case k.kind == keyPageUp:
+			b.epCursor = max(b.epCursor-b.listHeight(), 0)
+		case k.kind == keyPageDown:
+			b.epCursor = min(b.epCursor+b.listHeight(), count-1)
+		case k.kind == keyRune && k.r == ' ':
+			b.selected[b.epCursor] = !b.selected[b.epCursor]
+			b.epCursor = min(b.epCursor+1, count-1)
+		case k.kind == keyRune && k.r == 'a':
+			all := b.countSelected() < count
+			for i := range count {
+				b.selected[i] = all
+			}
+		case k.kind == keyEnter:
+			if b.countSelected() == 0 {
+				b.selected[b.epCursor] = true
+			}
+			return true, false
+		case k.kind == keyEsc || k.kind == keyLeft:
+			b.screen = screenResults
+			b.status = ""
+		case k.kind == keyRune && k.r == 'q':
+			return false, true
+		}
+	}
+	return false, false
+}
+
+func (b *browser) search() {
+	if strings.TrimSpace(b.query) == "" {
+		return
+	}
+
+	b.status = "Searching..."
+	b.screen = screenQuery
+	b.render()
+
+	results, err := b.ext.Search(b.query)
+	switch {
+	case err != nil:
+		b.status = color.RedString("Search failed: %v", err)
+	case len(results) == 0:
+		b.status = "No results"
+	default:
+		b.status = ""
+		b.results = results
+		b.cursor, b.offset = 0, 0
+		b.screen = screenResults
+		b.schedulePreview()
+	}
+}
+
+func (b *browser) moveCursor(delta int) {
+	if len(b.results) == 0 {
+		return
+	}
+	b.cursor = min(max(b.cursor+delta, 0), len(b.results)-1)
+	b.schedulePreview()
+}
+
+func (b *browser) schedulePreview() {
+	b.debounce.Reset(previewDelay)
+}
+
+func (b *browser) fetchPreview() {
+	if b.screen != screenResults || len(b.results) == 0 {
+		return
+	}
+	id := b.results[b.cursor].Id
+	if b.details[id] != nil || b.loading[id] {
+		return
+	}
+
+	b.loading[id] = true
+	delete(b.failures, id)
+	go func() {
+		details, err := b.ext.GetAnimeDetails(id)
+		b.previews <- previewResult{id: id, details: details, err: err}
+	}()
+}
+
+func (b *browser) openEpisodes() {
+	id := b.results[b.cursor].Id
+	details := b.details[id]
+	if details == nil {
+		b.status = "Loading episodes..."
+		b.render()
+
+		var err error
+		details, err = b.ext.GetAnimeDetails(id)
+		if err != nil {
+			b.status = color.RedString("Failed to load details: %v", err)
+			return
+		}
+		b.details[id] = details
+	}
+
+	if len(details.Episodes) == 0 {
+		b.status = "No episodes available"
+		return
+	}
+
+	b.status = ""
+	b.anime = details
+	b.selected = make(map[int]bool)
+	b.epCursor, b.epOffset = 0, 0
+	b.screen = screenEpisodes
+}
+
+func (b *browser) countSelected() int {
+	n := 0
+	for _, ok := range b.selected {
+		if ok {
+			n++
+		}
+	}
+	return n
+}
+
+// listHeight is the number of rows available to the result or episode list.
+func (b *browser) listHeight() int {
+	_, h := b.term.size()
+	if b.screen == screenResults {
+		return max((h-4)/2, 3)
+	}
+	return max(h-4, 3)
+}
+
+// scrollTo returns the list offset that keeps cursor visible.
+func scrollTo(cursor, offset, height int) int {
+	if cursor < offset {
+		return cursor
+	}
+	if cursor >= offset+height {
+		return cursor - height + 1
+	}
+	return offset
+}
+
+// numberLabel labels an episode in the list with the number episode
+// selectors use, or "-" for extras that have none.
+func numberLabel(e extractor.Episode) string {
+	if !e.Numbered {
+		return "-"
+	}
+	return strconv.FormatFloat(e.Number, 'f', -1, 64)
+}
+
+func (b *browser) render() {
+	w, h := b.term.size()
+	h = max(h, 8)
+	var lines []string
+	var help string
+
+	switch b.screen {
+	case screenQuery:
+		lines = append(lines,
+			bold("nem")+" — search anime",
+			"",
+			"Search: "+b.query+"█",
+			"",
+			b.status,
+		)
+		help = "enter search · esc quit"
+
+	case screenResults:
+		height := b.listHeight()
+		b.offset = scrollTo(b.cursor, b.offset, height)
+
+		lines = append(lines, fmt.Sprintf("Results for %s (%d)", bold(b.query), len(b.results)))
+		for i := b.offset; i < min(b.offset+height, len(b.results)); i++ {
+			r := b.results[i]
+			line := fmt.Sprintf("  [%s] %s", color.YellowString("%d", r.Id), r.Title)
+			if i == b.cursor {
+				line = highlight(fmt.Sprintf("> [%d] %s", r.Id, r.Title))
+			}
+			lines = append(lines, line)
+		}
+		for len(lines) < height+1 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, dim(strings.Repeat("─", w)))
+		lines = append(lines, b.previewLines(w, h-len(lines)-2)...)
+		help = "↑/↓ move · enter episodes · / search · q quit"
+
+	case screenEpisodes:
+		height := b.listHeight()
+		b.epOffset = scrollTo(b.epCursor, b.epOffset, height)
+
+		lines = append(lines, fmt.Sprintf("%s (%d episodes)", bold(b.anime.Title), len(b.anime.Episodes)))
+		for i := b.epOffset; i < min(b.epOffset+height, len(b.anime.Episodes)); i++ {
+			mark := "[ ]"
+			if b.selected[i] {
+				mark = color.GreenString("[x]")
+			}
+			title := fmt.Sprintf("%4s. %s", numberLabel(b.anime.Episodes[i]), b.anime.Episodes[i].Title)
+			if i == b.epCursor {
+				title = highlight(title)
+			}
+			lines = append(lines, mark+" "+title)
+		}
+		help = fmt.Sprintf("space select · a all · enter download (%d selected) · esc back", b.countSelected())
+	}
+
+	for len(lines) < h-2 {
+		lines = append(lines, "")
+	}
+	lines = append(lines[:h-2], b.statusLine(), dim(help))
+	b.term.draw(lines)
+}
+
+func (b *browser) statusLine() string {
+	if b.screen == screenQuery {
+		return ""
+	}
+	return b.status
+}
+
+func (b *browser) previewLines(width, height int) []string {
+	id := b.results[b.cursor].Id
+	details := b.details[id]
+	switch {
+	case details != nil:
+	case b.failures[id] != nil:
+		return []string{color.RedString("Failed to load details: %v", b.failures[id])}
+	default:
+		return []string{dim("Loading details...")}
+	}
+
+	lines := []string{bold(details.Title)}
+	if details.Subtitle != "" {
+		lines = append(lines, details.Subtitle)
+	}
+	lines = append(lines, fmt.Sprintf("%s %.1f  %s %s  %s %s",
+		color.YellowString("Rating"), details.Rating,
+		color.YellowString("Views"), details.Views,
+		color.YellowString("Episodes"), formatEpisodeCount(details)))
+	if len(details.Genres) > 0 {
+		lines = append(lines, dim(strings.Join(details.Genres, ", ")))
+	}
+	lines = append(lines, "")
+	lines = append(lines, wrap(details.Description, width)...)
+
+	if len(lines) > height {
+		lines = lines[:max(height, 0)]
+	}
+	return lines
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ppvan/nem/extractor"
+)
+
+// testBrowser returns a browser on the query screen reading keys, drawing
+// into the void.
+func testBrowser(keys <-chan key) *browser {
+	b := &browser{
+		term:     &terminal{out: bufio.NewWriter(io.Discard)},
+		keys:     keys,
+		details:  make(map[int]*extractor.AnimeDetail),
+		failures: make(map[int]error),
+		loading:  make(map[int]bool),
+		previews: make(chan previewResult, 4),
+		debounce: time.NewTimer(time.Hour),
+	}
+	b.debounce.Stop()
+	return b
+}
+
+func TestBrowserRunEnds(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		keys  []key
+		close bool
+		err   error
+	}{
+		{"Ctrl-C", context.Background(), []key{{kind: keyRune, r: 'f'}, {kind: keyCtrlC}}, false, context.Canceled},
+		{"cancelled context", canceled, nil, false, context.Canceled},
+		{"Esc quits", context.Background(), []key{{kind: keyEsc}}, false, nil},
+		{"stdin closed", context.Background(), nil, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := make(chan key, len(tt.keys))
+			for _, k := range tt.keys {
+				keys <- k
+			}
+			if tt.close {
+				close(keys)
+			}
+
+			done, err := testBrowser(keys).run(tt.ctx)
+			if done || !errors.Is(err, tt.err) {
+				t.Errorf("run() = %v, %v, want false, %v", done, err, tt.err)
+			}
+		})
+	}
+}
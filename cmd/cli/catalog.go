@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/ppvan/nem/extractor"
+	"github.com/urfave/cli/v3"
+)
+
+// listingFlags are shared by the commands printing a paginated listing.
+func listingFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:    "limit",
+			Aliases: []string{"l"},
+			Value:   30,
+			Usage:   "Max results",
+		},
+		&cli.IntFlag{
+			Name:    "page",
+			Aliases: []string{"p"},
+			Value:   1,
+			Usage:   "Result page",
+		},
+	}
+}
+
+func latestAction(ctx context.Context, cmd *cli.Command) error {
+	ext, err := newExtractor(cmd)
+	if err != nil {
+		return err
+	}
+
+	page, err := ext.Latest(cmd.Int("page"))
+	if err != nil {
+		return err
+	}
+
+	printListPage(page, cmd.Int("limit"))
+	return nil
+}
+
+func genreAction(ctx context.Context, cmd *cli.Command) error {
+	ext, err := newExtractor(cmd)
+	if err != nil {
+		return err
+	}
+
+	if cmd.NArg() < 1 {
+		genres, err := ext.Genres()
+		if err != nil {
+			return err
+		}
+		for _, g := range genres {
+			fmt.Printf("%s %s\n", color.YellowString("%-24s", g.Slug), g.Name)
+		}
+		return nil
+	}
+
+	page, err := ext.ByGenre(cmd.Args().First(), cmd.Int("page"))
+	if err != nil {
+		return err
+	}
+
+	printListPage(page, cmd.Int("limit"))
+	return nil
+}
+
+func seasonAction(ctx context.Context, cmd *cli.Command) error {
+	if cmd.NArg() < 1 {
+		return fmt.Errorf("missing year")
+	}
+
+	year, err := strconv.Atoi(cmd.Args().Get(0))
+	if err != nil {
+		return fmt.Errorf("invalid year: %w", err)
+	}
+	season, err := extractor.ParseSeason(cmd.Args().Get(1))
+	if err != nil {
+		return err
+	}
+
+	ext, err := newExtractor(cmd)
+	if err != nil {
+		return err
+	}
+
+	page, err := ext.BySeason(year, season, cmd.Int("page"))
+	if err != nil {
+		return err
+	}
+
+	printListPage(page, cmd.Int("limit"))
+	return nil
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseDay reads the --day flag of schedule. ok is false when every day
+// should be shown.
+func parseDay(value string) (day time.Weekday, ok bool, err error) {
+	value = strings.ToLower(value)
+	switch value {
+	case "", "all":
+		return 0, false, nil
+	case "today":
+		return time.Now().Weekday(), true, nil
+	}
+	if len(value) >= 3 {
+		if day, found := weekdayNames[value[:3]]; found {
+			return day, true, nil
+		}
+	}
+	return 0, false, fmt.Errorf("invalid day %q (available: today, mon, tue, wed, thu, fri, sat, sun)", value)
+}
+
+func scheduleAction(ctx context.Context, cmd *cli.Command) error {
+	only, filtered, err := parseDay(cmd.String("day"))
+	if err != nil {
+		return err
+	}
+
+	ext, err := newExtractor(cmd)
+	if err != nil {
+		return err
+	}
+
+	days, err := ext.Schedule()
+	if err != nil {
+		return err
+	}
+
+	today := time.Now().Weekday()
+	for _, day := range days {
+		if filtered && day.Weekday != only {
+			continue
+		}
+
+		heading := day.Weekday.String()
+		if day.Weekday == today {
+			heading += " (today)"
+		}
+		fmt.Println(bold(heading))
+		for _, anime := range day.Anime {
+			fmt.Printf("  [%s] %s%s\n", color.YellowString("%d", anime.Id), anime.Title, episodeLabel(anime))
+		}
+	}
+	return nil
+}
+
+// episodeLabel formats the latest episode of a listing entry for display.
+func episodeLabel(anime extractor.SimpleAnime) string {
+	if anime.Episode == "" {
+		return ""
+	}
+	return " " + dim("("+anime.Episode+")")
+}
@@ -4,51 +4,229 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/ppvan/nem/extractor"
+	"github.com/ppvan/nem/library"
 	"github.com/ppvan/nem/progressbar"
 	"github.com/urfave/cli/v3"
+	"golang.org/x/term"
 )
 
-var rangeRegex = regexp.MustCompile(`^(?P<start>\d+)(?:-(?P<end>\d+))?$`)
+// logOutput receives extractor logs. Commands drawing progress bars point
+// it at the bars so log lines scroll above them instead of tearing them.
+var logOutput = &switchWriter{w: os.Stderr}
+
+type switchWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (sw *switchWriter) Write(p []byte) (int, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return sw.w.Write(p)
+}
+
+func (sw *switchWriter) Set(w io.Writer) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.w = w
+}
+
+// cookieJarKey is where newExtractor keeps the opened cookie jar in the
+// root command's Metadata, for saveCookieJar to save when the command exits.
+const cookieJarKey = "cookieJar"
+
+// openCookieJar loads the persistent jar and imports the --cookies file.
+func openCookieJar(cmd *cli.Command) (*extractor.PersistentJar, error) {
+	path, err := extractor.DefaultCookieJarPath()
+	if err != nil {
+		return nil, fmt.Errorf("locate cookie jar: %w", err)
+	}
+
+	jar, err := extractor.NewPersistentJar(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if cookiesFile := cmd.String("cookies"); cookiesFile != "" {
+		f, err := os.Open(cookiesFile)
+		if err != nil {
+			return nil, fmt.Errorf("open cookies file: %w", err)
+		}
+		defer f.Close()
+
+		if _, err := jar.ImportNetscape(f); err != nil {
+			return nil, fmt.Errorf("import cookies file: %w", err)
+		}
+	}
+
+	return jar, nil
+}
+
+func saveCookieJar(ctx context.Context, cmd *cli.Command) error {
+	jar, ok := cmd.Root().Metadata[cookieJarKey].(*extractor.PersistentJar)
+	if !ok {
+		return nil
+	}
+	return jar.Save()
+}
+
+func cookiesClearAction(ctx context.Context, cmd *cli.Command) error {
+	path, err := extractor.DefaultCookieJarPath()
+	if err != nil {
+		return fmt.Errorf("locate cookie jar: %w", err)
+	}
+
+	jar, err := extractor.NewPersistentJar(path)
+	if err != nil {
+		return err
+	}
+	if err := jar.Clear(); err != nil {
+		return err
+	}
+
+	fmt.Println("Cookies cleared")
+	return nil
+}
+
+func cacheClearAction(ctx context.Context, cmd *cli.Command) error {
+	dir, err := extractor.DefaultCacheDir()
+	if err != nil {
+		return fmt.Errorf("locate cache: %w", err)
+	}
+	if err := extractor.NewCache(dir, 0).Clear(); err != nil {
+		return err
+	}
+
+	fmt.Println("Cache cleared")
+	return nil
+}
+
+// newExtractor creates the extractor shared by every command, configured
+// from the global flags.
+func newExtractor(cmd *cli.Command, opts ...extractor.Option) (*extractor.AniVietSubExtractor, error) {
+	logger := slog.New(slog.NewTextHandler(logOutput, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	root := cmd.Root()
+	jar, ok := root.Metadata[cookieJarKey].(*extractor.PersistentJar)
+	if !ok {
+		var err error
+		if jar, err = openCookieJar(cmd); err != nil {
+			return nil, err
+		}
+		if root.Metadata == nil {
+			root.Metadata = make(map[string]any)
+		}
+		root.Metadata[cookieJarKey] = jar
+	}
+
+	base := []extractor.Option{
+		extractor.WithLogger(logger),
+		extractor.WithCookieJar(jar),
+	}
+	if raw := cmd.String("proxy"); raw != "" {
+		proxy, err := extractor.ParseProxyURL(raw)
+		if err != nil {
+			return nil, err
+		}
+		base = append(base, extractor.WithProxy(proxy))
+	}
+	if !cmd.Bool("no-cache") {
+		dir, err := extractor.DefaultCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("locate cache: %w", err)
+		}
+		base = append(base, extractor.WithCache(extractor.NewCache(dir, extractor.DefaultCacheSize)))
+	}
+
+	opts = append(base, opts...)
+	ext, err := extractor.NewAniVietSubExtractor("", opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init extractor: %w", err)
+	}
+	return ext, nil
+}
 
 func searchAction(ctx context.Context, cmd *cli.Command) error {
-	if cmd.NArg() < 1 {
-		return fmt.Errorf("missing search query")
+	opts := extractor.SearchOptions{
+		Query: strings.Join(cmd.Args().Slice(), " "),
+		Genre: cmd.String("genre"),
+		Year:  cmd.Int("year"),
+		Page:  cmd.Int("page"),
+	}
+
+	var err error
+	if opts.Season, err = extractor.ParseSeason(cmd.String("season")); err != nil {
+		return err
+	}
+	if opts.Status, err = extractor.ParseStatus(cmd.String("status")); err != nil {
+		return err
+	}
+	if opts.Sort, err = extractor.ParseSortOrder(cmd.String("sort")); err != nil {
+		return err
+	}
+	if opts == (extractor.SearchOptions{Page: opts.Page}) {
+		return fmt.Errorf("missing search query or filter")
 	}
 
-	ext, err := extractor.NewAniVietSubExtractor("")
+	ext, err := newExtractor(cmd)
 	if err != nil {
-		cli.Exit("failed to init extractor", 1)
+		return err
 	}
 
-	query := cmd.Args().Get(0)
-	results, err := ext.Search(query)
+	page, err := ext.AdvancedSearch(opts)
 	if err != nil {
 		return err
 	}
 
-	limit := cmd.Int("limit")
-	count := min(limit, len(results))
+	printListPage(page, cmd.Int("limit"))
+	return nil
+}
 
+// printListPage prints up to limit results of page followed by a note when
+// the page was cut short and a pointer to the next page, if any.
+func printListPage(page *extractor.ListPage, limit int) {
+	count := min(limit, len(page.Results))
 	for i := range count {
-		fmt.Printf("[%s] %s\n", color.YellowString("%d", results[i].Id), results[i].Title)
+		fmt.Printf("[%s] %s%s\n", color.YellowString("%d", page.Results[i].Id), page.Results[i].Title, episodeLabel(page.Results[i]))
+	}
+	if count < len(page.Results) {
+		fmt.Println(dim(fmt.Sprintf("Showing %d of %d results on this page, raise --limit to see the rest", count, len(page.Results))))
+	}
+	if page.HasNext() {
+		fmt.Println(dim(fmt.Sprintf("Page %d/%d, use --page %d for more", page.Page, page.TotalPages, page.Page+1)))
 	}
-	return nil
 }
 
 func trendingAction(ctx context.Context, cmd *cli.Command) error {
-	ext, err := extractor.NewAniVietSubExtractor("")
+	period, err := extractor.ParsePeriod(cmd.String("period"))
+	if err != nil {
+		return err
+	}
+
+	ext, err := newExtractor(cmd)
 	if err != nil {
-		cli.Exit("failed to init extractor", 1)
+		return err
 	}
 
-	results, err := ext.Trending()
+	results, err := ext.Trending(period)
 	if err != nil {
 		return err
 	}
@@ -57,27 +235,51 @@ func trendingAction(ctx context.Context, cmd *cli.Command) error {
 	count := min(limit, len(results))
 
 	for i := range count {
-		fmt.Printf("[%s] %s\n", color.YellowString("%d", results[i].Id), results[i].Title)
+		r := results[i]
+		fmt.Printf("%3d. [%s] %s %s\n", r.Rank, color.YellowString("%d", r.Id), r.Title, dim(fmt.Sprintf("(%d views)", r.Views)))
 	}
 	return nil
 }
 
-func detailsAction(ctx context.Context, cmd *cli.Command) error {
-	if cmd.NArg() < 1 {
-		return fmt.Errorf("missing anime ID")
+// parseAnimeArg reads an anime ID or a site URL. An episode page URL also
+// yields the ID of its episode.
+func parseAnimeArg(arg string) (extractor.Link, error) {
+	if arg == "" {
+		return extractor.Link{}, errors.New("missing anime ID or URL")
+	}
+	if id, err := strconv.Atoi(arg); err == nil {
+		return extractor.Link{AnimeID: id}, nil
 	}
+	if !strings.Contains(arg, "/") {
+		return extractor.Link{}, fmt.Errorf("invalid ID %q", arg)
+	}
+	return extractor.ParseLink(arg)
+}
 
-	id, err := strconv.Atoi(cmd.Args().Get(0))
+// episodeSelector is the --episode flag, or the episode an episode page URL
+// points to when the flag is not set.
+func episodeSelector(cmd *cli.Command, link extractor.Link) (string, error) {
+	if cmd.IsSet("episode") || link.EpisodeID == "" {
+		if cmd.String("episode") == "" {
+			return "", errors.New("missing --episode")
+		}
+		return cmd.String("episode"), nil
+	}
+	return "id:" + link.EpisodeID, nil
+}
+
+func detailsAction(ctx context.Context, cmd *cli.Command) error {
+	link, err := parseAnimeArg(cmd.Args().First())
 	if err != nil {
-		return fmt.Errorf("invalid ID: %w", err)
+		return err
 	}
 
-	ext, err := extractor.NewAniVietSubExtractor("")
+	ext, err := newExtractor(cmd)
 	if err != nil {
-		cli.Exit("failed to init extractor", 1)
+		return err
 	}
 
-	details, err := ext.GetAnimeDetails(id)
+	details, err := ext.GetAnimeDetails(link.AnimeID)
 	if err != nil {
 		return err
 	}
@@ -89,146 +291,401 @@ func detailsAction(ctx context.Context, cmd *cli.Command) error {
 	fmt.Printf("%v: %s\n", color.YellowString("Description"), details.Description)
 	fmt.Printf("%v: %.1f\n", color.YellowString("Rating"), details.Rating)
 	fmt.Printf("%v: %s\n", color.YellowString("Views"), details.Views)
-	fmt.Printf("%v: %s\n", color.YellowString("Episodes"), details.TotalEpisodes)
+	if len(details.AltTitles) > 0 {
+		fmt.Printf("%v: %s\n", color.YellowString("Alt titles"), strings.Join(details.AltTitles, "; "))
+	}
+	if len(details.Genres) > 0 {
+		fmt.Printf("%v: %s\n", color.YellowString("Genres"), strings.Join(details.Genres, ", "))
+	}
+	if details.Studio != "" {
+		fmt.Printf("%v: %s\n", color.YellowString("Studio"), details.Studio)
+	}
+	if details.Season != "" || details.Year != 0 {
+		fmt.Printf("%v: %s\n", color.YellowString("Aired"), strings.TrimSpace(fmt.Sprintf("%s %s", details.Season, formatYear(details.Year))))
+	}
+	if details.Status != extractor.StatusUnknown {
+		fmt.Printf("%v: %s\n", color.YellowString("Status"), details.Status)
+	}
+	fmt.Printf("%v: %s\n", color.YellowString("Episodes"), formatEpisodeCount(details))
+	if details.Duration != "" {
+		fmt.Printf("%v: %s\n", color.YellowString("Duration"), details.Duration)
+	}
+	if details.Poster != "" {
+		fmt.Printf("%v: %s\n", color.YellowString("Poster"), details.Poster)
+	}
+	if details.Banner != "" {
+		fmt.Printf("%v: %s\n", color.YellowString("Banner"), details.Banner)
+	}
+	if len(details.Related) > 0 {
+		fmt.Printf("%v:\n", color.YellowString("Related"))
+		for _, r := range details.Related {
+			fmt.Printf("  [%v] %s\n", color.YellowString("%d", r.Id), r.Title)
+		}
+	}
 
 	return nil
 }
 
-func downloadAction(ctx context.Context, cmd *cli.Command) error {
-	id := cmd.IntArg("id")
-	episodeValue := cmd.String("episode")
-	s, e, err := parseRange(episodeValue)
+func formatYear(year int) string {
+	if year == 0 {
+		return ""
+	}
+	return strconv.Itoa(year)
+}
 
+// formatEpisodeCount shows available episodes against the announced total,
+// e.g. "5/12", or "5/?" while the total is unknown.
+func formatEpisodeCount(details *extractor.AnimeDetail) string {
+	if details.TotalEpisodes == 0 {
+		return fmt.Sprintf("%d/?", len(details.Episodes))
+	}
+	return fmt.Sprintf("%d/%d", len(details.Episodes), details.TotalEpisodes)
+}
+
+func downloadAction(ctx context.Context, cmd *cli.Command) error {
+	link, err := parseAnimeArg(cmd.StringArg("id"))
+	if err != nil {
+		return err
+	}
+	selector, err := episodeSelector(cmd, link)
 	if err != nil {
-		return fmt.Errorf("--episode flag format invalid")
+		return err
 	}
 
 	output := cmd.String("output")
-	info, err := os.Stat(output)
-	if errors.Is(err, os.ErrNotExist) || !info.IsDir() {
-		return fmt.Errorf("directory '%s' does not exist.", output)
+	if err := checkOutputDir(output); err != nil {
+		return err
 	}
 
-	if !info.IsDir() {
-		return fmt.Errorf("directory '%s' is not a directory.", output)
+	strategy, err := extractor.ParseStrategy(cmd.String("strategy"))
+	if err != nil {
+		return err
 	}
 
-	ext, err := extractor.NewAniVietSubExtractor("")
+	ext, err := newExtractor(cmd, extractor.WithStrategy(strategy))
 	if err != nil {
-		cli.Exit("failed to init extractor", 1)
+		return err
 	}
 
-	details, err := ext.GetAnimeDetails(id)
+	details, err := ext.GetAnimeDetails(link.AnimeID)
 	if err != nil {
 		return err
 	}
 
-	if s < 1 || e > len(details.Episodes) {
-		return fmt.Errorf("invalid episode number: %s (available: 1-%d)", episodeValue, len(details.Episodes))
+	episodes, err := selectEpisodes(details.Episodes, selector)
+	if err != nil {
+		return err
 	}
 
-	for _, episode := range details.Episodes[s-1 : e] {
-		filename := fmt.Sprintf("%s - %s.%s", details.Title, episode.Title, "ts")
-		episodeFilePath := filepath.Join(output, filename)
+	opts, err := newDownloadOptions(cmd, output)
+	if err != nil {
+		return err
+	}
 
-		file, err := os.Create(episodeFilePath)
-		if err != nil {
-			return err
-		}
-		defer file.Close()
+	return downloadEpisodes(ctx, ext, details, episodes, opts)
+}
 
-		fmt.Printf("Downloading %s\n", filename)
-		bar := progressbar.New(progressbar.Options{
-			Total:          100,
-			ShowPercentage: true,
-		})
+func checkOutputDir(output string) error {
+	info, err := os.Stat(output)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("directory '%s' does not exist.", output)
+	}
+	if err != nil {
+		return err
+	}
 
-		bar.Start()
+	if !info.IsDir() {
+		return fmt.Errorf("directory '%s' is not a directory.", output)
+	}
+	return nil
+}
 
-		err = ext.Download(episode, file, func(progress float64) {
-			bar.Update(int(progress * 100))
-		})
+// progressMode reads the global --progress and --no-progress flags.
+func progressMode(cmd *cli.Command) (progressbar.Mode, error) {
+	if cmd.Bool("no-progress") {
+		return progressbar.ModeNone, nil
+	}
+	return progressbar.ParseMode(cmd.String("progress"))
+}
 
-		bar.Finish()
+// labelWidth is the width of the episode title column next to each bar.
+const labelWidth = 24
 
-		if err != nil {
-			return fmt.Errorf("%s download error: %w", episodeFilePath, err)
-		}
+// barOptions returns the progress bar layout shared by download commands,
+// sizing the bar so the whole line fits the terminal.
+func barOptions(label string, total int) progressbar.Options {
+	const statsWidth = 46 // percentage, size, speed, elapsed and ETA columns
+
+	width := 40
+	if w, _, err := term.GetSize(int(os.Stderr.Fd())); err == nil {
+		width = min(max(w-labelWidth-statsWidth-2, 10), 40)
 	}
 
-	return nil
+	return progressbar.Options{
+		Total:          total,
+		Width:          width,
+		ShowPercentage: true,
+		Label:          label,
+		LabelWidth:     labelWidth,
+		ShowSize:       true,
+		ShowSpeed:      true,
+		ShowElapsed:    true,
+		ShowETA:        true,
+	}
 }
 
-func playlistAction(ctx context.Context, cmd *cli.Command) error {
-	if cmd.NArg() < 1 {
-		return fmt.Errorf("missing anime ID")
-	}
+// downloadOptions are the settings shared by the commands that download
+// episodes.
+type downloadOptions struct {
+	output  string
+	jobs    int
+	mode    progressbar.Mode
+	format  string
+	force   bool
+	nfo     bool
+	library *library.Library
+	// cover is the poster embedded into mkv files, fetched once per batch.
+	cover []byte
+}
 
-	id, err := strconv.Atoi(cmd.Args().Get(0))
+// newDownloadOptions reads the download flags of cmd and opens the library.
+func newDownloadOptions(cmd *cli.Command, output string) (downloadOptions, error) {
+	mode, err := progressMode(cmd)
 	if err != nil {
-		return fmt.Errorf("invalid ID: %w", err)
+		return downloadOptions{}, err
 	}
 
-	episodeNum := cmd.Int("episode")
-	output := cmd.String("output")
+	format := cmd.String("format")
+	if format != formatTS && format != formatMKV {
+		return downloadOptions{}, fmt.Errorf("invalid format %q (want ts or mkv)", format)
+	}
 
-	ext, err := extractor.NewAniVietSubExtractor("")
+	lib, err := openLibrary()
 	if err != nil {
-		cli.Exit("failed to init extractor", 1)
+		return downloadOptions{}, err
 	}
 
-	details, err := ext.GetAnimeDetails(id)
-	if err != nil {
-		return err
+	return downloadOptions{
+		output:  output,
+		jobs:    cmd.Int("jobs"),
+		mode:    mode,
+		format:  format,
+		force:   cmd.Bool("force"),
+		nfo:     cmd.Bool("nfo"),
+		library: lib,
+	}, nil
+}
+
+// downloadEpisodes downloads episodes of details into opts.output, running
+// up to opts.jobs downloads at once. Each active episode gets its own
+// progress bar below an overall bar for the whole batch, written to stderr.
+//
+// Episodes already in the library are skipped unless opts.force is set, and
+// every finished download is recorded there.
+//
+// Cancelling ctx stops every running download and removes its partial
+// file; episodes that already finished are kept.
+func downloadEpisodes(ctx context.Context, ext *extractor.AniVietSubExtractor, details *extractor.AnimeDetail, episodes []extractor.Episode, opts downloadOptions) error {
+	multi := progressbar.NewMulti(progressbar.MultiOptions{Output: os.Stderr, Mode: opts.mode})
+	overall := multi.Add(barOptions(fmt.Sprintf("Total (%d episodes)", len(episodes)), len(episodes)*100))
+
+	logOutput.Set(multi)
+	defer logOutput.Set(os.Stderr)
+
+	multi.Start()
+	defer multi.Stop()
+
+	warn := func(format string, a ...any) {
+		multi.Printf(color.YellowString("Warning")+" "+format, a...)
 	}
+	if opts.nfo {
+		if err := writeShowMetadata(ctx, ext, details, opts.output, warn); err != nil {
+			return fmt.Errorf("write show metadata: %w", err)
+		}
+	}
+	if opts.format == formatMKV {
+		opts.cover = fetchCover(ctx, ext, details, warn)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	sem := make(chan struct{}, max(opts.jobs, 1))
+
+loop:
+	for _, episode := range episodes {
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			break
+		}
 
-	if episodeNum < 1 || episodeNum > len(details.Episodes) {
-		return fmt.Errorf("invalid episode number: %d (available: 1-%d)", episodeNum, len(details.Episodes))
+		if entry, ok := opts.library.Find(details.Id, episode.Id); ok && !opts.force && entry.Check() == library.StatusPresent {
+			multi.Printf("%s %s: already downloaded to %s\n", color.CyanString("Skipped"), episode.Title, entry.Path)
+			overall.Add(100)
+			continue
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break loop
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := downloadEpisode(ctx, ext, details, episode, opts, multi, overall); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}()
 	}
+	wg.Wait()
 
-	episode := details.Episodes[episodeNum-1]
-	playlist, err := ext.GetM3UPlaylist(episode)
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	return firstErr
+}
+
+// partSuffix marks files that are still being written. An episode only gets
+// its final name once every segment is on disk, so an interrupted download
+// never looks like a complete one.
+const partSuffix = ".part"
+
+func downloadEpisode(ctx context.Context, ext *extractor.AniVietSubExtractor, details *extractor.AnimeDetail, episode extractor.Episode, opts downloadOptions, multi *progressbar.Multi, overall *progressbar.ProgressBar) error {
+	episodeFilePath := episodePath(details, episode, opts)
+	filename := filepath.Base(episodeFilePath)
+	partPath := episodeFilePath + partSuffix
+	if opts.format == formatMKV {
+		// The stream is saved as it arrives and remuxed once complete.
+		partPath = strings.TrimSuffix(episodeFilePath, filepath.Ext(episodeFilePath)) + "." + formatTS + partSuffix
+	}
+
+	// Specials get their own season folder under --nfo.
+	if err := os.MkdirAll(filepath.Dir(partPath), 0o755); err != nil {
+		return err
+	}
+	file, err := os.Create(partPath)
 	if err != nil {
 		return err
 	}
+	defer file.Close()
 
-	return os.WriteFile(output, playlist, 0644)
-}
+	bar := multi.Add(barOptions(episode.Title, 1))
 
-func parseRange(input string) (int, int, error) {
-	matches := rangeRegex.FindStringSubmatch(input)
-	if matches == nil {
-		return 0, 0, errors.New("invalid format: must be 'N' or 'N-M'")
-	}
+	var last int
+	var lastBytes int64
+	var progress extractor.Progress
+	err = ext.DownloadContext(ctx, episode, file, func(p extractor.Progress) {
+		progress = p
+		bar.SetTotal(p.Segments)
+		bar.SetBytes(p.Bytes)
+		bar.Update(p.Segment)
 
-	startStr := matches[rangeRegex.SubexpIndex("start")]
-	endStr := matches[rangeRegex.SubexpIndex("end")]
+		current := int(p.Fraction() * 100)
+		overall.AddBytes(p.Bytes - lastBytes)
+		overall.Add(current - last)
+		last, lastBytes = current, p.Bytes
+	})
 
-	start, err := parsePositiveInt32(startStr)
+	multi.Remove(bar)
+
+	if err == nil {
+		err = file.Close()
+	}
+	if err == nil && opts.format == formatMKV {
+		err = remuxEpisode(partPath, episodeFilePath, episodeMetadata(details, episode, opts.cover))
+		os.Remove(partPath)
+	}
+	if err == nil && opts.format == formatTS {
+		err = os.Rename(partPath, episodeFilePath)
+	}
 	if err != nil {
-		return 0, 0, fmt.Errorf("start value error: %v", err)
+		file.Close()
+		os.Remove(partPath)
+		if errors.Is(err, context.Canceled) {
+			multi.Printf("%s %s\n", color.YellowString("Cancelled"), filename)
+			return err
+		}
+		multi.Printf("%s %s: %v\n", color.RedString("Failed"), filename, err)
+		return fmt.Errorf("%s download error: %w", episodeFilePath, err)
 	}
 
-	// If end is empty (single number case), end = start
-	if endStr == "" {
-		return start, start, nil
+	if len(progress.Damaged) > 0 {
+		multi.Printf("%s %s: %d of %d segments could not be repaired and may play with glitches:\n",
+			color.YellowString("Warning"), filename, len(progress.Damaged), progress.Segments)
+		for _, damage := range progress.Damaged {
+			multi.Printf("  %v\n", damage)
+		}
 	}
 
-	end, err := parsePositiveInt32(endStr)
+	err = opts.library.Add(library.Entry{
+		AnimeID:      details.Id,
+		AnimeTitle:   details.Title,
+		EpisodeID:    episode.Id,
+		EpisodeHash:  episode.Hash,
+		EpisodeTitle: episode.Title,
+		Path:         episodeFilePath,
+		Size:         fileSize(episodeFilePath, progress.Bytes),
+		Duration:     progress.Duration,
+		SourceURL:    episode.Href,
+		DownloadedAt: time.Now(),
+	})
 	if err != nil {
-		return 0, 0, fmt.Errorf("end value error: %v", err)
+		multi.Printf("%s %s was not added to the library: %v\n", color.YellowString("Warning"), filename, err)
 	}
 
-	return start, end, nil
+	if opts.nfo {
+		if err := writeEpisodeMetadata(details, episode, episodeFilePath, progress.Duration); err != nil {
+			multi.Printf("%s %v\n", color.YellowString("Warning"), err)
+		}
+	}
+	return nil
 }
 
-func parsePositiveInt32(s string) (int, error) {
-	val, err := strconv.ParseInt(s, 10, 32)
+func playlistAction(ctx context.Context, cmd *cli.Command) error {
+	link, err := parseAnimeArg(cmd.Args().First())
+	if err != nil {
+		return err
+	}
+	selector, err := episodeSelector(cmd, link)
+	if err != nil {
+		return err
+	}
+
+	output := cmd.String("output")
+
+	ext, err := newExtractor(cmd)
+	if err != nil {
+		return err
+	}
+
+	details, err := ext.GetAnimeDetails(link.AnimeID)
 	if err != nil {
-		return 0, errors.New("number out of range or invalid")
+		return err
+	}
+
+	episodes, err := selectEpisodes(details.Episodes, selector)
+	if err != nil {
+		return err
 	}
-	if val <= 0 {
-		return 0, errors.New("number must be positive (> 0)")
+	if len(episodes) > 1 {
+		return fmt.Errorf("--episode selects %d episodes, a playlist needs exactly one", len(episodes))
 	}
-	return int(val), nil
+
+	playlist, err := ext.GetM3UPlaylist(episodes[0])
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(output, playlist, 0644)
 }
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ppvan/nem/extractor"
+)
+
+// episodeRangeRegex matches "N", "N-M" and the open range "N-".
+var episodeRangeRegex = regexp.MustCompile(`^(?P<start>\d+)(?P<dash>-)?(?P<end>\d+)?$`)
+
+// selectEpisodes returns the episodes matched by selector in list order,
+// each at most once. The selector is a comma separated list of:
+//
+//	N, N-M    episodes numbered N, or N to M
+//	N-        episode N and every later one
+//	latest    the last episode
+//	last:N    the last N episodes
+//	id:X      the episode with ID X
+//	anything else is looked up as an episode ID, then as a title
+//
+// Numbers, latest and last:N count regular episodes only, so specials such
+// as "12.5" or "SP" never shift them; select those by title instead.
+// Episode IDs are numeric too, so a number no episode has is tried as an ID
+// before it is reported as missing. Titles match case-insensitively,
+// exactly if possible and as a substring otherwise, so "OVA" selects every
+// OVA.
+func selectEpisodes(episodes []extractor.Episode, selector string) ([]extractor.Episode, error) {
+	if len(episodes) == 0 {
+		return nil, errors.New("no episodes available")
+	}
+
+	selected := make([]bool, len(episodes))
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		indices, err := matchEpisodes(episodes, term)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range indices {
+			selected[i] = true
+		}
+	}
+
+	var result []extractor.Episode
+	for i, ok := range selected {
+		if ok {
+			result = append(result, episodes[i])
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("empty episode selection %q", selector)
+	}
+	return result, nil
+}
+
+// matchEpisodes returns the indices of the episodes matched by one term of
+// a selector.
+func matchEpisodes(episodes []extractor.Episode, term string) ([]int, error) {
+	regular := regularEpisodes(episodes)
+
+	if strings.EqualFold(term, "latest") {
+		return regular[len(regular)-1:], nil
+	}
+	if count, ok := strings.CutPrefix(strings.ToLower(term), "last:"); ok {
+		c, err := parsePositiveInt32(count)
+		if err != nil {
+			return nil, fmt.Errorf("invalid episode selector %q: %v", term, err)
+		}
+		return regular[max(len(regular)-c, 0):], nil
+	}
+	if id, ok := strings.CutPrefix(strings.ToLower(term), "id:"); ok {
+		if i := episodeByID(episodes, id); i >= 0 {
+			return []int{i}, nil
+		}
+		return nil, fmt.Errorf("no episode with ID %s", id)
+	}
+
+	if m := episodeRangeRegex.FindStringSubmatch(term); m != nil {
+		start, err := parsePositiveInt32(m[episodeRangeRegex.SubexpIndex("start")])
+		if err != nil {
+			return nil, fmt.Errorf("invalid episode selector %q: %v", term, err)
+		}
+		end := start
+		if endStr := m[episodeRangeRegex.SubexpIndex("end")]; endStr != "" {
+			if end, err = parsePositiveInt32(endStr); err != nil {
+				return nil, fmt.Errorf("invalid episode selector %q: %v", term, err)
+			}
+		} else if m[episodeRangeRegex.SubexpIndex("dash")] != "" {
+			end = math.MaxInt32
+		}
+
+		if start > end {
+			return nil, fmt.Errorf("invalid episode range %q: start is after end", term)
+		}
+		var indices []int
+		for _, i := range regular {
+			if n := episodes[i].Number; n >= float64(start) && n <= float64(end) {
+				indices = append(indices, i)
+			}
+		}
+		if len(indices) > 0 {
+			return indices, nil
+		}
+		if i := episodeByID(episodes, term); i >= 0 {
+			return []int{i}, nil
+		}
+		first, last := episodes[regular[0]].Number, episodes[regular[len(regular)-1]].Number
+		return nil, fmt.Errorf("episode %s out of range (available: %g-%g)", term, first, last)
+	}
+
+	if i := episodeByID(episodes, term); i >= 0 {
+		return []int{i}, nil
+	}
+	if i := slices.IndexFunc(episodes, func(e extractor.Episode) bool { return strings.EqualFold(e.Title, term) }); i >= 0 {
+		return []int{i}, nil
+	}
+	var indices []int
+	lower := strings.ToLower(term)
+	for i, e := range episodes {
+		if strings.Contains(strings.ToLower(e.Title), lower) {
+			indices = append(indices, i)
+		}
+	}
+	if len(indices) == 0 {
+		return nil, fmt.Errorf("no episode matches %q", term)
+	}
+	return indices, nil
+}
+
+// regularEpisodes returns the indices of the regular episodes, or of every
+// episode when the list only has extras.
+func regularEpisodes(episodes []extractor.Episode) []int {
+	var indices []int
+	for i, e := range episodes {
+		if e.Kind == extractor.EpisodeRegular {
+			indices = append(indices, i)
+		}
+	}
+	if len(indices) == 0 {
+		return indexRange(0, len(episodes)-1)
+	}
+	return indices
+}
+
+func episodeByID(episodes []extractor.Episode, id string) int {
+	return slices.IndexFunc(episodes, func(e extractor.Episode) bool { return e.Id == id })
+}
+
+func indexRange(from, to int) []int {
+	indices := make([]int, 0, to-from+1)
+	for i := from; i <= to; i++ {
+		indices = append(indices, i)
+	}
+	return indices
+}
+
+func parsePositiveInt32(s string) (int, error) {
+	val, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return 0, errors.New("number out of range or invalid")
+	}
+	if val <= 0 {
+		return 0, errors.New("number must be positive (> 0)")
+	}
+	return int(val), nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ppvan/nem/extractor"
+)
+
+// testEpisodes lists episodes 1 to 12 with IDs 105430 to 105441, followed
+// by a recap, a special and two OVAs in site order.
+func testEpisodes() []extractor.Episode {
+	var episodes []extractor.Episode
+	for n := 1; n <= 12; n++ {
+		episodes = append(episodes, extractor.Episode{
+			Id: strconv.Itoa(105429 + n), Title: strconv.Itoa(n), Number: float64(n), Kind: extractor.EpisodeRegular,
+		})
+	}
+	return append(episodes,
+		extractor.Episode{Id: "105500", Title: "12.5", Number: 12.5, Kind: extractor.EpisodeSpecial},
+		extractor.Episode{Id: "105501", Title: "SP", Kind: extractor.EpisodeSpecial},
+		extractor.Episode{Id: "105502", Title: "OVA 1", Number: 1, Kind: extractor.EpisodeOVA},
+		extractor.Episode{Id: "105503", Title: "OVA 2", Number: 2, Kind: extractor.EpisodeOVA},
+	)
+}
+
+func TestSelectEpisodes(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     string // selected titles, comma separated
+		err      string
+	}{
+		{selector: "3", want: "3"},
+		{selector: "2-4", want: "2,3,4"},
+		{selector: "11-", want: "11,12"},
+		{selector: "10-12", want: "10,11,12"},
+		{selector: "latest", want: "12"},
+		{selector: "LATEST", want: "12"},
+		{selector: "last:3", want: "10,11,12"},
+		{selector: "last:50", want: "1,2,3,4,5,6,7,8,9,10,11,12"},
+		{selector: "id:105500", want: "12.5"},
+		{selector: "105501", want: "SP"},
+		{selector: "12.5", want: "12.5"},
+		{selector: "sp", want: "SP"},
+		{selector: "ova", want: "OVA 1,OVA 2"},
+		{selector: "OVA 2", want: "OVA 2"},
+		{selector: " 1 , latest ", want: "1,12"},
+
+		// Every episode at most once, in list order.
+		{selector: "1-3,2,3-4", want: "1,2,3,4"},
+		{selector: "12,1,latest,last:1", want: "1,12"},
+		{selector: "ova,OVA 1,5", want: "5,OVA 1,OVA 2"},
+
+		// A number no episode has is tried as an ID.
+		{selector: "105431", want: "2"},
+		{selector: "99", err: "episode 99 out of range (available: 1-12)"},
+		{selector: "0", err: "must be positive"},
+		{selector: "99999999999", err: "out of range or invalid"},
+
+		{selector: "5-3", err: `invalid episode range "5-3": start is after end`},
+		{selector: "13-20", err: "out of range"},
+		{selector: "last:0", err: "invalid episode selector"},
+		{selector: "last:x", err: "invalid episode selector"},
+		{selector: "id:1", err: "no episode with ID 1"},
+		{selector: "movie", err: `no episode matches "movie"`},
+		{selector: ",,", err: "empty episode selection"},
+		{selector: "1,nope", err: `no episode matches "nope"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			got, err := selectEpisodes(testEpisodes(), tt.selector)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var titles []string
+			for _, e := range got {
+				titles = append(titles, e.Title)
+			}
+			if strings.Join(titles, ",") != tt.want {
+				t.Errorf("selected %v, want %s", titles, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectEpisodesOnlyExtras(t *testing.T) {
+	episodes := testEpisodes()[14:]
+	got, err := selectEpisodes(episodes, "latest")
+	if err != nil || len(got) != 1 || got[0].Title != "OVA 2" {
+		t.Errorf("latest = %v, %v, want OVA 2", got, err)
+	}
+	got, err = selectEpisodes(episodes, "1")
+	if err != nil || len(got) != 1 || got[0].Title != "OVA 1" {
+		t.Errorf("1 = %v, %v, want OVA 1", got, err)
+	}
+}
+
+func TestSelectEpisodesEmptyList(t *testing.T) {
+	if _, err := selectEpisodes(nil, "1"); err == nil {
+		t.Error("selecting from no episodes succeeded")
+	}
+}
+
+func TestRegularEpisodes(t *testing.T) {
+	if got := regularEpisodes(testEpisodes()); !slices.Equal(got, indexRange(0, 11)) {
+		t.Errorf("regularEpisodes = %v, want the first 12", got)
+	}
+}
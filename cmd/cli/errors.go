@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"net"
+
+	"github.com/ppvan/nem/extractor"
+)
+
+// Exit codes returned by nem. Scripts can rely on these to decide whether a
+// failure is worth retrying.
+const (
+	exitError         = 1
+	exitNotFound      = 3
+	exitBlocked       = 4
+	exitRateLimited   = 5
+	exitLayoutChanged = 6
+	exitDecrypt       = 7
+	exitNetwork       = 8
+	exitInterrupted   = 130 // 128 + SIGINT, as shells report it
+)
+
+// errorHints maps extractor failures to an exit code and a hint telling the
+// user what to do about it.
+var errorHints = []struct {
+	target error
+	code   int
+	hint   string
+}{
+	{extractor.ErrNotFound, exitNotFound, "check the anime ID with `nem search <title>`"},
+	{extractor.ErrBlocked, exitBlocked, "Cloudflare blocked the request; try `nem cookies clear`, import browser cookies with `--cookies`, or use `--proxy`"},
+	{extractor.ErrRateLimited, exitRateLimited, "the server is rate limiting downloads; wait a bit or use `--strategy adaptive`"},
+	{extractor.ErrLayoutChanged, exitLayoutChanged, "the site layout changed; update nem or report an issue at https://github.com/ppvan/nem/issues"},
+	{extractor.ErrDecrypt, exitDecrypt, "the playlist could not be decrypted; update nem or report an issue at https://github.com/ppvan/nem/issues"},
+}
+
+// classifyError returns the exit code and an optional hint for err.
+func classifyError(err error) (int, string) {
+	for _, h := range errorHints {
+		if errors.Is(err, h.target) {
+			return h.code, h.hint
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return exitNetwork, "network error; check your connection or `--proxy` setting"
+	}
+
+	return exitError, ""
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/ppvan/nem/extractor"
+)
+
+func TestClassifyError(t *testing.T) {
+	httpError := func(status int) error {
+		return fmt.Errorf("anime 1: %w", &extractor.HTTPError{URL: "https://animevietsub.example/", StatusCode: status})
+	}
+	dnsError := &url.Error{Op: "Get", URL: "https://animevietsub.example/", Err: &net.DNSError{Err: "no such host", Name: "animevietsub.example"}}
+
+	tests := []struct {
+		name string
+		err  error
+		code int
+		hint bool
+	}{
+		{"not found", extractor.ErrNotFound, exitNotFound, true},
+		{"blocked", extractor.ErrBlocked, exitBlocked, true},
+		{"rate limited", extractor.ErrRateLimited, exitRateLimited, true},
+		{"layout changed", extractor.ErrLayoutChanged, exitLayoutChanged, true},
+		{"decrypt", extractor.ErrDecrypt, exitDecrypt, true},
+		{"wrapped sentinel", fmt.Errorf("parse anime 1: %w: %w", extractor.ErrLayoutChanged, errors.New("no title")), exitLayoutChanged, true},
+		{"doubly wrapped sentinel", fmt.Errorf("download: %w", fmt.Errorf("segment 3: %w", extractor.ErrRateLimited)), exitRateLimited, true},
+		{"404", httpError(404), exitNotFound, true},
+		{"410", httpError(410), exitNotFound, true},
+		{"403", httpError(403), exitBlocked, true},
+		{"429", httpError(429), exitRateLimited, true},
+		{"500", httpError(500), exitError, false},
+		{"network", fmt.Errorf("fetch: %w", dnsError), exitNetwork, true},
+		{"sentinel before network", fmt.Errorf("%w: %w", extractor.ErrBlocked, dnsError), exitBlocked, true},
+		{"other", errors.New("directory 'x' does not exist."), exitError, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, hint := classifyError(tt.err)
+			if code != tt.code {
+				t.Errorf("code = %d, want %d", code, tt.code)
+			}
+			if (hint != "") != tt.hint {
+				t.Errorf("hint = %q, want one: %v", hint, tt.hint)
+			}
+		})
+	}
+}
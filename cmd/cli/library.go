@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/ppvan/nem/library"
+	"github.com/urfave/cli/v3"
+)
+
+func openLibrary() (*library.Library, error) {
+	path, err := library.DefaultPath()
+	if err != nil {
+		return nil, fmt.Errorf("locate library: %w", err)
+	}
+	return library.Open(path)
+}
+
+// statusLabel colors the status of a library entry for display.
+func statusLabel(status library.Status) string {
+	switch status {
+	case library.StatusMissing:
+		return color.RedString("missing")
+	case library.StatusChanged:
+		return color.YellowString("changed")
+	}
+	return color.GreenString("ok")
+}
+
+func libraryListAction(ctx context.Context, cmd *cli.Command) error {
+	lib, err := openLibrary()
+	if err != nil {
+		return err
+	}
+
+	type summary struct {
+		id       int
+		title    string
+		episodes int
+		size     int64
+		missing  int
+	}
+	var anime []*summary
+	byID := make(map[int]*summary)
+	for _, e := range lib.Entries() {
+		s := byID[e.AnimeID]
+		if s == nil {
+			s = &summary{id: e.AnimeID, title: e.AnimeTitle}
+			byID[e.AnimeID] = s
+			anime = append(anime, s)
+		}
+		s.episodes++
+		s.size += e.Size
+		if e.Check() != library.StatusPresent {
+			s.missing++
+		}
+	}
+
+	if len(anime) == 0 {
+		fmt.Println("Library is empty")
+		return nil
+	}
+	for _, s := range anime {
+		line := fmt.Sprintf("[%s] %s: %d episodes, %s", color.YellowString("%d", s.id), s.title, s.episodes, formatSize(s.size))
+		if s.missing > 0 {
+			line += color.RedString(" (%d missing)", s.missing)
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+func libraryShowAction(ctx context.Context, cmd *cli.Command) error {
+	if cmd.NArg() < 1 {
+		return fmt.Errorf("missing anime ID")
+	}
+
+	id, err := strconv.Atoi(cmd.Args().Get(0))
+	if err != nil {
+		return fmt.Errorf("invalid ID: %w", err)
+	}
+
+	lib, err := openLibrary()
+	if err != nil {
+		return err
+	}
+
+	entries := lib.Anime(id)
+	if len(entries) == 0 {
+		return fmt.Errorf("anime %d is not in the library", id)
+	}
+
+	fmt.Printf("%v: %s\n", color.YellowString("Title"), entries[0].AnimeTitle)
+	for _, e := range entries {
+		fmt.Printf("%-8s %s  %s  %s  %s\n", statusLabel(e.Check()), e.EpisodeTitle,
+			formatSize(e.Size), e.Duration.Round(time.Second), e.Path)
+		fmt.Printf("         %s\n", dim(fmt.Sprintf("downloaded %s from %s", e.DownloadedAt.Format(time.DateTime), e.SourceURL)))
+	}
+	return nil
+}
+
+func libraryScanAction(ctx context.Context, cmd *cli.Command) error {
+	lib, err := openLibrary()
+	if err != nil {
+		return err
+	}
+
+	result, err := lib.Scan(cmd.Args().Slice()...)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range result.Moved {
+		fmt.Printf("%s %s - %s: %s\n", color.CyanString("Moved"), e.AnimeTitle, e.EpisodeTitle, e.Path)
+	}
+	for _, e := range result.Changed {
+		fmt.Printf("%s %s - %s: %s\n", color.YellowString("Changed"), e.AnimeTitle, e.EpisodeTitle, e.Path)
+	}
+	for _, e := range result.Missing {
+		fmt.Printf("%s %s - %s: %s\n", color.RedString("Missing"), e.AnimeTitle, e.EpisodeTitle, e.Path)
+	}
+	fmt.Printf("%d moved, %d changed, %d missing\n", len(result.Moved), len(result.Changed), len(result.Missing))
+	if len(result.Missing) > 0 {
+		fmt.Println(dim("Pass the directories the files were moved to, or run `nem library prune` to forget them."))
+	}
+	return nil
+}
+
+func libraryPruneAction(ctx context.Context, cmd *cli.Command) error {
+	lib, err := openLibrary()
+	if err != nil {
+		return err
+	}
+
+	removed, err := lib.Prune()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range removed {
+		fmt.Printf("%s %s - %s\n", color.RedString("Removed"), e.AnimeTitle, e.EpisodeTitle)
+	}
+	fmt.Printf("%d entries removed\n", len(removed))
+	return nil
+}
+
+// formatSize renders n bytes using binary units, e.g. "1.2 GiB".
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	units := []string{"KiB", "MiB", "GiB", "TiB"}
+	f := float64(n)
+	i := -1
+	for f >= unit && i < len(units)-1 {
+		f /= unit
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", f, units[i])
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"runtime/debug"
+	"syscall"
 
 	"github.com/fatih/color"
 	"github.com/urfave/cli/v3"
@@ -38,15 +41,78 @@ func main() {
 		fmt.Printf("version=%s revision=%s%s\n", cmd.Root().Version, revision, dirty)
 	}
 
-	cmd := &cli.Command{
+	cmd := newApp(version)
+
+	// The first Ctrl-C cancels the running command so it can restore the
+	// terminal and clean up; a second one kills the process as usual.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := cmd.Run(ctx, os.Args)
+	stop()
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintln(os.Stderr, color.YellowString("Interrupted"))
+		os.Exit(exitInterrupted)
+	}
+	if err != nil {
+		code, hint := classifyError(err)
+		fmt.Fprintf(os.Stderr, "%s: %v\n", color.RedString("Error"), err)
+		if hint != "" {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", color.YellowString("Hint"), hint)
+		}
+		os.Exit(code)
+	}
+}
+
+// newApp builds the nem command tree. Without a subcommand nem browses, so
+// the root command takes the browse flags too.
+func newApp(version string) *cli.Command {
+	return &cli.Command{
 		Name:    "nem",
 		Version: version,
 		Usage:   "Anime downloader CLI",
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:    "proxy",
+				Usage:   "Proxy URL for all traffic (http://, https:// or socks5://, optionally with user:pass@)",
+				Sources: cli.EnvVars("NEM_PROXY"),
+			},
+			&cli.StringFlag{
+				Name:      "cookies",
+				Usage:     "Import cookies from a Netscape cookies.txt file exported from a browser",
+				TakesFile: true,
+			},
+			&cli.BoolFlag{
+				Name:  "no-cache",
+				Usage: "Always fetch details, search results and listings from the site",
+			},
+			&cli.StringFlag{
+				Name:  "progress",
+				Value: "auto",
+				Usage: "Progress output on stderr (auto, bar, plain, json, none); auto falls back to plain lines when stderr is not a terminal",
+			},
+			&cli.BoolFlag{
+				Name:  "no-progress",
+				Usage: "Disable progress output, same as --progress none",
+			},
+		}, browseFlags()...),
+		After:  saveCookieJar,
+		Action: browseAction,
 		Commands: []*cli.Command{
+			{
+				Name:      "browse",
+				Usage:     "Interactively search, pick episodes and download",
+				ArgsUsage: "[query]",
+				Flags:     browseFlags(),
+				Action:    browseAction,
+			},
 			{
 				Name:      "search",
-				Usage:     "Search anime by title",
-				ArgsUsage: "<query>",
+				Usage:     "Search anime by title and filters",
+				ArgsUsage: "[query]",
 				Flags: []cli.Flag{
 					&cli.IntFlag{
 						Name:    "limit",
@@ -54,30 +120,55 @@ func main() {
 						Value:   20,
 						Usage:   "Max results",
 					},
+					&cli.StringFlag{
+						Name:  "genre",
+						Usage: "Genre slug as used in the site URLs, e.g. hanh-dong",
+					},
+					&cli.IntFlag{
+						Name:  "year",
+						Usage: "Release year",
+					},
+					&cli.StringFlag{
+						Name:  "season",
+						Usage: "Release season (spring, summer, fall, winter)",
+					},
+					&cli.StringFlag{
+						Name:  "status",
+						Usage: "Airing status (upcoming, ongoing, completed)",
+					},
+					&cli.StringFlag{
+						Name:  "sort",
+						Usage: "Sort order (latest, views, rating, name)",
+					},
+					&cli.IntFlag{
+						Name:    "page",
+						Aliases: []string{"p"},
+						Value:   1,
+						Usage:   "Result page",
+					},
 				},
 				Action: searchAction,
 			},
 			{
 				Name:      "info",
 				Usage:     "Get anime details infomation",
-				ArgsUsage: "<id>",
+				ArgsUsage: "<id|url>",
 				Action:    detailsAction,
 			},
 			{
 				Name:      "download",
 				Usage:     "Download anime episodes",
-				ArgsUsage: "[id]",
+				ArgsUsage: "<id|url>",
 				Arguments: []cli.Argument{
-					&cli.IntArg{
+					&cli.StringArg{
 						Name: "id",
 					},
 				},
 				Flags: []cli.Flag{
 					&cli.StringFlag{
-						Name:     "episode",
-						Aliases:  []string{"e"},
-						Usage:    "Episode number or range (i.e 5, 2-11, 1-12)",
-						Required: true,
+						Name:    "episode",
+						Aliases: []string{"e"},
+						Usage:   "Episodes to download: 5, 2-11, 1,3,5-7, 10-, latest, last:3, or an episode title or ID; defaults to the episode of an episode URL",
 					},
 					&cli.StringFlag{
 						Name:      "output",
@@ -86,19 +177,42 @@ func main() {
 						TakesFile: true,
 						Required:  true,
 					},
+					&cli.StringFlag{
+						Name:  "strategy",
+						Value: "greedy",
+						Usage: "Segment download strategy (greedy, adaptive, parallel)",
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Value: "ts",
+						Usage: "Container of the saved episodes (ts, mkv); mkv embeds titles, description and cover art",
+					},
+					&cli.IntFlag{
+						Name:    "jobs",
+						Aliases: []string{"j"},
+						Value:   1,
+						Usage:   "Number of episodes to download at once",
+					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "Download episodes again even if they are in the library",
+					},
+					&cli.BoolFlag{
+						Name:  "nfo",
+						Usage: "Write Kodi/Jellyfin metadata and artwork and use a media server folder layout",
+					},
 				},
 				Action: downloadAction,
 			},
 			{
 				Name:      "playlist",
 				Usage:     "Get the M3U8 playlist of the episode",
-				ArgsUsage: "<id>",
+				ArgsUsage: "<id|url>",
 				Flags: []cli.Flag{
-					&cli.IntFlag{
-						Name:     "episode",
-						Aliases:  []string{"e"},
-						Usage:    "Episode number",
-						Required: true,
+					&cli.StringFlag{
+						Name:    "episode",
+						Aliases: []string{"e"},
+						Usage:   "Episode: a number, latest, or an episode title or ID; defaults to the episode of an episode URL",
 					},
 					&cli.StringFlag{
 						Name:      "output",
@@ -112,8 +226,13 @@ func main() {
 			},
 			{
 				Name:  "trending",
-				Usage: "Get trending anime of the season",
+				Usage: "Get the most viewed anime of a period",
 				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "period",
+						Value: "season",
+						Usage: "Ranking period (day, week, month, season, year)",
+					},
 					&cli.IntFlag{
 						Name:    "limit",
 						Aliases: []string{"l"},
@@ -123,11 +242,132 @@ func main() {
 				},
 				Action: trendingAction,
 			},
+			{
+				Name:   "latest",
+				Usage:  "List recently updated anime",
+				Flags:  listingFlags(),
+				Action: latestAction,
+			},
+			{
+				Name:      "genre",
+				Usage:     "List anime of a genre, or all genres without an argument",
+				ArgsUsage: "[slug]",
+				Flags:     listingFlags(),
+				Action:    genreAction,
+			},
+			{
+				Name:      "season",
+				Usage:     "List anime released in a year, optionally narrowed to a season",
+				ArgsUsage: "<year> [spring|summer|fall|winter]",
+				Flags:     listingFlags(),
+				Action:    seasonAction,
+			},
+			{
+				Name:  "schedule",
+				Usage: "Show the weekly airing schedule",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "day",
+						Aliases: []string{"d"},
+						Usage:   "Only show one day (today, mon, tue, wed, thu, fri, sat, sun)",
+					},
+				},
+				Action: scheduleAction,
+			},
+			{
+				Name:  "cookies",
+				Usage: "Manage the persistent cookie jar",
+				Commands: []*cli.Command{
+					{
+						Name:   "clear",
+						Usage:  "Remove all saved cookies",
+						Action: cookiesClearAction,
+					},
+				},
+			},
+			{
+				Name:  "library",
+				Usage: "Inspect the index of downloaded episodes",
+				Commands: []*cli.Command{
+					{
+						Name:   "list",
+						Usage:  "List downloaded anime",
+						Action: libraryListAction,
+					},
+					{
+						Name:      "show",
+						Usage:     "Show the downloaded episodes of an anime",
+						ArgsUsage: "<id>",
+						Action:    libraryShowAction,
+					},
+					{
+						Name:      "scan",
+						Usage:     "Check recorded files and follow the ones moved below the given directories",
+						ArgsUsage: "[dir...]",
+						Action:    libraryScanAction,
+					},
+					{
+						Name:   "prune",
+						Usage:  "Forget episodes whose file no longer exists",
+						Action: libraryPruneAction,
+					},
+				},
+			},
+			{
+				Name:  "cache",
+				Usage: "Manage the page cache",
+				Commands: []*cli.Command{
+					{
+						Name:   "clear",
+						Usage:  "Remove all cached pages",
+						Action: cacheClearAction,
+					},
+				},
+			},
 		},
 	}
+}
 
-	if err := cmd.Run(context.Background(), os.Args); err != nil {
-		fmt.Fprintf(os.Stderr, "%s: %v\n", color.RedString("Error"), err)
-		os.Exit(1)
+// browseFlags are the flags of browse, which are also accepted by the root
+// command. They are local so the other commands do not inherit them.
+func browseFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:      "output",
+			Aliases:   []string{"o"},
+			Value:     ".",
+			Usage:     "Output directory",
+			TakesFile: true,
+			Local:     true,
+		},
+		&cli.StringFlag{
+			Name:  "strategy",
+			Value: "greedy",
+			Usage: "Segment download strategy (greedy, adaptive, parallel)",
+			Local: true,
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "ts",
+			Usage: "Container of the saved episodes (ts, mkv); mkv embeds titles, description and cover art",
+			Local: true,
+		},
+		&cli.IntFlag{
+			Name:    "jobs",
+			Aliases: []string{"j"},
+			Value:   1,
+			Usage:   "Number of episodes to download at once",
+			Local:   true,
+		},
+		&cli.BoolFlag{
+			Name:  "force",
+			Usage: "Download episodes again even if they are in the library",
+			Local: true,
+		},
+		&cli.BoolFlag{
+			Name:  "nfo",
+			Usage: "Write Kodi/Jellyfin metadata and artwork and use a media server folder layout",
+			Local: true,
+		},
 	}
 }
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRootCommandBrowseFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		// An unsupported proxy fails while the extractor is set up, which
+		// happens only once the browse flags were read and validated.
+		{"no args", nil, "unsupported proxy scheme"},
+		{"browse flags", []string{"--format", "mkv", "-j", "2", "--nfo", "-o", "."}, "unsupported proxy scheme"},
+		{"invalid format", []string{"--format", "avi"}, `invalid format "avi"`},
+		{"unknown command", []string{"foo"}, `unknown command "foo"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv("HOME", dir)
+			t.Setenv("XDG_CACHE_HOME", dir)
+			t.Setenv("XDG_CONFIG_HOME", dir)
+			t.Setenv("XDG_DATA_HOME", dir)
+			t.Setenv("NEM_PROXY", "ftp://127.0.0.1")
+
+			args := append([]string{"nem", "--no-cache"}, tt.args...)
+			err := newApp("test").Run(context.Background(), args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Run(%q) = %v, want error containing %q", args, err, tt.want)
+			}
+		})
+	}
+}
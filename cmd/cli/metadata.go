@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+
+	"github.com/ppvan/nem/extractor"
+	"github.com/ppvan/nem/mkv"
+)
+
+// episodeMetadata describes episode for the tags of an mkv file. Extras
+// carry no part number, as they are not numbered among the episodes.
+// Chapters and Subtitles stay empty: the site hardsubs its videos and has
+// no chapter marks.
+func episodeMetadata(details *extractor.AnimeDetail, episode extractor.Episode, cover []byte) *mkv.Metadata {
+	number := 0
+	if episode.Kind == extractor.EpisodeRegular {
+		number = int(episode.Number)
+	}
+	return &mkv.Metadata{
+		Title:        details.Title,
+		EpisodeTitle: episode.Title,
+		Episode:      number,
+		Description:  details.Description,
+		Genres:       details.Genres,
+		Year:         details.Year,
+		Cover:        cover,
+	}
+}
+
+// fetchCover downloads the poster embedded into every mkv file of a batch.
+// A missing poster is reported through warn; the files are still written,
+// just without artwork.
+func fetchCover(ctx context.Context, ext *extractor.AniVietSubExtractor, details *extractor.AnimeDetail, warn func(format string, a ...any)) []byte {
+	if details.Poster == "" {
+		return nil
+	}
+	cover, err := ext.FetchImageContext(ctx, details.Poster)
+	if err != nil {
+		warn("cover art could not be fetched: %v\n", err)
+		return nil
+	}
+	return cover
+}
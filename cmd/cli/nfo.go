@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ppvan/nem/extractor"
+	"github.com/ppvan/nem/nfo"
+)
+
+// nfoSeason is the season regular episodes are filed under. The site lists
+// each season as its own anime, so a show folder never holds more than one.
+// Specials and OVAs go to season 0, which media servers show as Specials.
+const (
+	nfoSeason   = 1
+	nfoSpecials = 0
+)
+
+// episodeNumber is the season and episode number episode is filed under.
+// Regular episodes keep the number from their title; extras are numbered by
+// their order among the extras of the show.
+func episodeNumber(details *extractor.AnimeDetail, episode extractor.Episode) (season, number int) {
+	if episode.Kind == extractor.EpisodeRegular {
+		return nfoSeason, int(episode.Number)
+	}
+	for _, e := range details.Episodes {
+		if e.Kind != extractor.EpisodeRegular {
+			number++
+		}
+		if e.Id == episode.Id {
+			break
+		}
+	}
+	return nfoSpecials, number
+}
+
+// episodePath is where episode is saved. With --nfo, files are laid out the
+// way Kodi and Jellyfin expect: "Show (Year)/Season 01/Show S01E05.ts".
+func episodePath(details *extractor.AnimeDetail, episode extractor.Episode, opts downloadOptions) string {
+	if !opts.nfo {
+		name := nfo.SanitizeName(fmt.Sprintf("%s - %s", details.Title, episode.Title))
+		return filepath.Join(opts.output, name+"."+opts.format)
+	}
+	season, number := episodeNumber(details, episode)
+	dir := nfo.SeasonDir(nfo.ShowDir(opts.output, details), season)
+	return filepath.Join(dir, nfo.EpisodeName(details, season, number)+"."+opts.format)
+}
+
+// writeShowMetadata creates the show folder with its tvshow.nfo, poster.jpg
+// and fanart.jpg. Artwork that cannot be fetched is reported through warn
+// and skipped.
+func writeShowMetadata(ctx context.Context, ext *extractor.AniVietSubExtractor, details *extractor.AnimeDetail, output string, warn func(format string, a ...any)) error {
+	showDir := nfo.ShowDir(output, details)
+	if err := os.MkdirAll(nfo.SeasonDir(showDir, nfoSeason), 0o755); err != nil {
+		return err
+	}
+	if err := nfo.Write(filepath.Join(showDir, "tvshow.nfo"), nfo.NewTVShow(details)); err != nil {
+		return err
+	}
+
+	artwork := []struct{ name, url string }{
+		{"poster.jpg", details.Poster},
+		{"fanart.jpg", details.Banner},
+	}
+	for _, art := range artwork {
+		path := filepath.Join(showDir, art.name)
+		if art.url == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+
+		data, err := ext.FetchImageContext(ctx, art.url)
+		if err == nil {
+			err = os.WriteFile(path, data, 0o644)
+		}
+		if err != nil {
+			warn("%s could not be saved: %v\n", art.name, err)
+		}
+	}
+	return nil
+}
+
+// writeEpisodeMetadata writes the .nfo file next to the video at path.
+func writeEpisodeMetadata(details *extractor.AnimeDetail, episode extractor.Episode, path string, runtime time.Duration) error {
+	season, number := episodeNumber(details, episode)
+	doc := nfo.NewEpisode(details, episode, season, number, runtime)
+	return nfo.Write(strings.TrimSuffix(path, filepath.Ext(path))+".nfo", doc)
+}
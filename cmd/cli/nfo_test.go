@@ -0,0 +1,37 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/ppvan/nem/extractor"
+)
+
+func TestEpisodePath(t *testing.T) {
+	details := &extractor.AnimeDetail{
+		Title: "Fate/Zero",
+		Year:  2011,
+		Episodes: []extractor.Episode{
+			{Id: "1", Title: "01", Number: 1, Kind: extractor.EpisodeRegular},
+			{Id: "2", Title: "SP: Q/A", Kind: extractor.EpisodeSpecial},
+			{Id: "3", Title: "02", Number: 2, Kind: extractor.EpisodeRegular},
+		},
+	}
+	tests := []struct {
+		episode int
+		nfo     bool
+		want    string
+	}{
+		{0, false, "out/Fate_Zero - 01.ts"},
+		{1, false, "out/Fate_Zero - SP_ Q_A.ts"},
+		{2, true, "out/Fate_Zero (2011)/Season 01/Fate_Zero S01E02.ts"},
+		{1, true, "out/Fate_Zero (2011)/Season 00/Fate_Zero S00E01.ts"},
+	}
+	for _, tt := range tests {
+		opts := downloadOptions{output: "out", format: formatTS, nfo: tt.nfo}
+		got := episodePath(details, details.Episodes[tt.episode], opts)
+		if got != filepath.FromSlash(tt.want) {
+			t.Errorf("episodePath(%q, nfo=%v) = %q, want %q", details.Episodes[tt.episode].Title, tt.nfo, got, tt.want)
+		}
+	}
+}
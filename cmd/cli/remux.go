@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ppvan/nem/mkv"
+)
+
+// Containers episodes can be saved in.
+const (
+	formatTS  = "ts"
+	formatMKV = "mkv"
+)
+
+// remuxEpisode converts the MPEG-TS file src to the Matroska file dst. The
+// output is written next to dst and renamed once complete.
+func remuxEpisode(src, dst string, meta *mkv.Metadata) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	part := dst + partSuffix
+	out, err := os.Create(part)
+	if err != nil {
+		return err
+	}
+
+	err = mkv.Remux(out, in, meta)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(part, dst)
+	}
+	if err != nil {
+		os.Remove(part)
+		return fmt.Errorf("remux: %w", err)
+	}
+	return nil
+}
+
+// fileSize returns the size of the file at path, or fallback if it cannot
+// be read.
+func fileSize(path string, fallback int64) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fallback
+	}
+	return info.Size()
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+type keyKind int
+
+const (
+	keyRune keyKind = iota
+	keyEnter
+	keyBackspace
+	keyEsc
+	keyCtrlC
+	keyTab
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyPageUp
+	keyPageDown
+)
+
+type key struct {
+	kind keyKind
+	r    rune
+}
+
+// terminal is a full screen, raw mode view of the controlling terminal.
+type terminal struct {
+	fd    int
+	state *term.State
+	out   *bufio.Writer
+}
+
+var (
+	keysOnce sync.Once
+	keys     chan key
+)
+
+// readKeys starts the goroutine decoding stdin into keys. It runs for the
+// rest of the process, since a blocked read on stdin cannot be cancelled.
+func readKeys() <-chan key {
+	keysOnce.Do(func() {
+		keys = make(chan key, 16)
+		go func() {
+			buf := make([]byte, 64)
+			for {
+				n, err := os.Stdin.Read(buf)
+				if err != nil {
+					close(keys)
+					return
+				}
+				for _, k := range decodeKeys(buf[:n]) {
+					keys <- k
+				}
+			}
+		}()
+	})
+	return keys
+}
+
+// decodeKeys splits one read from a raw mode terminal into key presses.
+func decodeKeys(b []byte) []key {
+	var out []key
+	for len(b) > 0 {
+		switch c := b[0]; {
+		case c == 0x1b && len(b) >= 3 && b[1] == '[':
+			// A CSI sequence runs over parameter and intermediate bytes
+			// up to a final byte; unknown ones such as Delete (ESC [ 3 ~)
+			// are dropped whole.
+			end := 2
+			for end < len(b) && b[end] >= 0x20 && b[end] <= 0x3f {
+				end++
+			}
+			if end == len(b) {
+				return out
+			}
+			if k, ok := csiKey(string(b[2:end]), b[end]); ok {
+				out = append(out, k)
+			}
+			b = b[end+1:]
+		case c == 0x1b && len(b) >= 3 && b[1] == 'O':
+			if k, ok := csiKey("", b[2]); ok {
+				out = append(out, k)
+			}
+			b = b[3:]
+		case c == 0x1b:
+			out = append(out, key{kind: keyEsc})
+			b = b[1:]
+		case c == '\r' || c == '\n':
+			out = append(out, key{kind: keyEnter})
+			b = b[1:]
+		case c == 0x7f || c == 0x08:
+			out = append(out, key{kind: keyBackspace})
+			b = b[1:]
+		case c == 0x03:
+			out = append(out, key{kind: keyCtrlC})
+			b = b[1:]
+		case c == '\t':
+			out = append(out, key{kind: keyTab})
+			b = b[1:]
+		case c < 0x20:
+			b = b[1:]
+		default:
+			r, size := utf8.DecodeRune(b)
+			out = append(out, key{kind: keyRune, r: r})
+			b = b[size:]
+		}
+	}
+	return out
+}
+
+// csiKey maps the parameters and final byte of an escape sequence to the
+// key it encodes. Modifiers such as Ctrl in ESC [ 1 ; 5 A are ignored.
+func csiKey(params string, final byte) (key, bool) {
+	switch final {
+	case 'A':
+		return key{kind: keyUp}, true
+	case 'B':
+		return key{kind: keyDown}, true
+	case 'C':
+		return key{kind: keyRight}, true
+	case 'D':
+		return key{kind: keyLeft}, true
+	case '~':
+		switch params {
+		case "5":
+			return key{kind: keyPageUp}, true
+		case "6":
+			return key{kind: keyPageDown}, true
+		}
+	}
+	return key{}, false
+}
+
+// openTerminal switches to the alternate screen in raw mode.
+func openTerminal() (*terminal, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return nil, fmt.Errorf("interactive mode needs a terminal")
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, fmt.Errorf("enable raw mode: %w", err)
+	}
+
+	t := &terminal{
+		fd:    fd,
+		state: state,
+		out:   bufio.NewWriter(os.Stdout),
+	}
+	t.out.WriteString("\x1b[?1049h\x1b[?25l") // Alternate screen, hide cursor
+	t.out.Flush()
+	return t, nil
+}
+
+// close restores the screen and the terminal mode.
+func (t *terminal) close() {
+	t.out.WriteString("\x1b[?25h\x1b[?1049l")
+	t.out.Flush()
+	term.Restore(t.fd, t.state)
+}
+
+func (t *terminal) size() (int, int) {
+	w, h, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || w <= 0 || h <= 0 {
+		return 80, 24
+	}
+	return w, h
+}
+
+// draw replaces the screen with lines, cutting them to the terminal size.
+func (t *terminal) draw(lines []string) {
+	w, h := t.size()
+	t.out.WriteString("\x1b[H\x1b[2J")
+	for i, line := range lines {
+		if i >= h {
+			break
+		}
+		if i > 0 {
+			t.out.WriteString("\r\n")
+		}
+		t.out.WriteString(truncate(line, w))
+	}
+	t.out.Flush()
+}
+
+// truncate cuts s to width visible runes, skipping over ANSI color codes.
+func truncate(s string, width int) string {
+	var sb strings.Builder
+	visible := 0
+	inEscape := false
+	for _, r := range s {
+		switch {
+		case r == 0x1b:
+			inEscape = true
+		case inEscape:
+			if r == 'm' {
+				inEscape = false
+			}
+		default:
+			if visible == width {
+				sb.WriteString("\x1b[0m")
+				return sb.String()
+			}
+			visible++
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// wrap breaks text into lines of at most width runes on word boundaries.
+func wrap(text string, width int) []string {
+	var lines []string
+	var line strings.Builder
+	for _, word := range strings.Fields(text) {
+		if line.Len() > 0 && utf8.RuneCountInString(line.String())+1+utf8.RuneCountInString(word) > width {
+			lines = append(lines, line.String())
+			line.Reset()
+		}
+		if line.Len() > 0 {
+			line.WriteByte(' ')
+		}
+		line.WriteString(word)
+	}
+	if line.Len() > 0 {
+		lines = append(lines, line.String())
+	}
+	return lines
+}
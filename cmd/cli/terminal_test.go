@@ -0,0 +1,32 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []key
+	}{
+		{"arrows", "\x1b[A\x1b[B\x1b[C\x1b[D", []key{{kind: keyUp}, {kind: keyDown}, {kind: keyRight}, {kind: keyLeft}}},
+		{"application arrows", "\x1bOA\x1bOB", []key{{kind: keyUp}, {kind: keyDown}}},
+		{"page keys", "\x1b[5~\x1b[6~", []key{{kind: keyPageUp}, {kind: keyPageDown}}},
+		{"modified arrow", "\x1b[1;5A", []key{{kind: keyUp}}},
+		{"delete", "\x1b[3~x", []key{{kind: keyRune, r: 'x'}}},
+		{"function key", "\x1b[15~x", []key{{kind: keyRune, r: 'x'}}},
+		{"SS3 function key", "\x1bOPx", []key{{kind: keyRune, r: 'x'}}},
+		{"truncated sequence", "\x1b[1;", nil},
+		{"escape", "\x1b", []key{{kind: keyEsc}}},
+		{"text", "hé\r", []key{{kind: keyRune, r: 'h'}, {kind: keyRune, r: 'é'}, {kind: keyEnter}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeKeys([]byte(tt.input)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeKeys(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
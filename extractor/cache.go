@@ -0,0 +1,257 @@
+package extractor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// CacheKind groups cached pages that share a time to live.
+type CacheKind string
+
+const (
+	CacheDetails  CacheKind = "details"
+	CacheSearch   CacheKind = "search"
+	CacheListing  CacheKind = "listing"
+	CacheTrending CacheKind = "trending"
+)
+
+// DefaultCacheTTLs are the times to live used by NewCache. Listings and
+// details expire first, since new episodes show up there; search results
+// and the ranking change slowly.
+var DefaultCacheTTLs = map[CacheKind]time.Duration{
+	CacheDetails:  30 * time.Minute,
+	CacheSearch:   time.Hour,
+	CacheListing:  15 * time.Minute,
+	CacheTrending: time.Hour,
+}
+
+// DefaultCacheSize is the default cap on the total size of a Cache.
+const DefaultCacheSize = 64 << 20
+
+// Cache stores page bodies on disk, keyed by request. Each kind lives in its
+// own directory and an entry's modification time is when it was stored.
+//
+// Only metadata pages go through the cache; episode, player and playlist
+// pages carry short-lived tokens and are always fetched.
+type Cache struct {
+	mu      sync.Mutex
+	dir     string
+	maxSize int64
+	ttls    map[CacheKind]time.Duration
+
+	// size is the total size of the entries as of the last prune plus what
+	// was written since; scanned is false until the first prune.
+	size    int64
+	scanned bool
+}
+
+// DefaultCacheDir returns the cache location inside the user cache
+// directory.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "nem", "http"), nil
+}
+
+// NewCache returns a cache rooted at dir holding at most maxSize bytes; the
+// oldest entries are evicted first. A zero maxSize uses DefaultCacheSize.
+func NewCache(dir string, maxSize int64) *Cache {
+	if maxSize <= 0 {
+		maxSize = DefaultCacheSize
+	}
+	ttls := make(map[CacheKind]time.Duration, len(DefaultCacheTTLs))
+	for kind, ttl := range DefaultCacheTTLs {
+		ttls[kind] = ttl
+	}
+	return &Cache{dir: dir, maxSize: maxSize, ttls: ttls}
+}
+
+// SetTTL changes how long entries of kind stay fresh. A zero ttl disables
+// caching for kind.
+func (c *Cache) SetTTL(kind CacheKind, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttls[kind] = ttl
+}
+
+// Clear removes every cached entry.
+func (c *Cache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size, c.scanned = 0, false
+	return os.RemoveAll(c.dir)
+}
+
+func (c *Cache) path(kind CacheKind, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, string(kind), hex.EncodeToString(sum[:]))
+}
+
+func (c *Cache) get(kind CacheKind, key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ttl := c.ttls[kind]
+	if ttl <= 0 {
+		return nil, false
+	}
+
+	path := c.path(kind, key)
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > ttl {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+func (c *Cache) put(kind CacheKind, key string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ttls[kind] <= 0 {
+		return nil
+	}
+
+	path := c.path(kind, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	var replaced int64
+	if info, err := os.Stat(path); err == nil {
+		replaced = info.Size()
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// Walking the whole cache on every write is wasteful; do it once per
+	// process and afterwards only when the writes push it past maxSize.
+	c.size += int64(len(data)) - replaced
+	if c.scanned && c.size <= c.maxSize {
+		return nil
+	}
+	return c.prune()
+}
+
+// prune drops expired entries and then the oldest ones until the cache
+// fits in maxSize.
+func (c *Cache) prune() error {
+	type entry struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+
+	var entries []entry
+	var total int64
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		kind := CacheKind(filepath.Base(filepath.Dir(path)))
+		if time.Since(info.ModTime()) > c.ttls[kind] {
+			os.Remove(path)
+			return nil
+		}
+		entries = append(entries, entry{path, info.Size(), info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	slices.SortFunc(entries, func(a, b entry) int { return a.modTime.Compare(b.modTime) })
+	for _, e := range entries {
+		if total <= c.maxSize {
+			break
+		}
+		if err := os.Remove(e.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		total -= e.size
+	}
+	c.size, c.scanned = total, true
+	return nil
+}
+
+// cacheKey identifies a request by method, URL and body.
+func cacheKey(req *http.Request) string {
+	key := req.Method + " " + req.URL.String()
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := io.ReadAll(body)
+			body.Close()
+			key += "\n" + string(data)
+		}
+	}
+	return key
+}
+
+// fetchCached returns the body of a successful response to req, serving it
+// from the cache when a fresh entry of kind exists. Other responses are
+// returned as *HTTPError and never cached. Failures keep their sentinel
+// errors; only transport failures are labeled as network errors.
+func (ex *AniVietSubExtractor) fetchCached(kind CacheKind, req *http.Request) ([]byte, error) {
+	var key string
+	if ex.cache != nil {
+		key = cacheKey(req)
+		if data, ok := ex.cache.get(kind, key); ok {
+			ex.logger.Debug("cache hit", "kind", kind, "url", req.URL.String())
+			return data, nil
+		}
+	}
+
+	r, err := ex.doWithRetry(req)
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) {
+			return nil, fmt.Errorf("network error: %w", err)
+		}
+		return nil, err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode < 200 || r.StatusCode > 299 {
+		return nil, newHTTPError(r)
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+
+	if ex.cache != nil {
+		if err := ex.cache.put(kind, key, data); err != nil {
+			ex.logger.Warn("failed to write cache", "error", err)
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,151 @@
+package extractor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheEvictsOldestPastMaxSize(t *testing.T) {
+	c := NewCache(t.TempDir(), 250)
+	page := bytes.Repeat([]byte("x"), 100)
+
+	for _, key := range []string{"a", "b", "c"} {
+		if err := c.put(CacheDetails, key, page); err != nil {
+			t.Fatal(err)
+		}
+		// Entries are ordered by modification time.
+		old := time.Now().Add(-time.Minute)
+		if key == "a" {
+			old = old.Add(-time.Minute)
+		}
+		os.Chtimes(c.path(CacheDetails, key), old, old)
+	}
+
+	if _, ok := c.get(CacheDetails, "a"); ok {
+		t.Error("oldest entry survived the size cap")
+	}
+	for _, key := range []string{"b", "c"} {
+		if data, ok := c.get(CacheDetails, key); !ok || !bytes.Equal(data, page) {
+			t.Errorf("entry %q missing after prune", key)
+		}
+	}
+	if c.size != 200 {
+		t.Errorf("tracked size = %d, want 200", c.size)
+	}
+}
+
+func TestCacheExpiresEntries(t *testing.T) {
+	c := NewCache(t.TempDir(), 0)
+	if err := c.put(CacheListing, "k", []byte("page")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.get(CacheListing, "k"); !ok {
+		t.Fatal("fresh entry missing")
+	}
+
+	old := time.Now().Add(-DefaultCacheTTLs[CacheListing] - time.Minute)
+	os.Chtimes(c.path(CacheListing, "k"), old, old)
+	if _, ok := c.get(CacheListing, "k"); ok {
+		t.Error("expired entry served")
+	}
+
+	c.SetTTL(CacheSearch, 0)
+	c.put(CacheSearch, "k", []byte("page"))
+	if _, ok := c.get(CacheSearch, "k"); ok {
+		t.Error("entry of a disabled kind served")
+	}
+}
+
+func TestFetchCached(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		err    error
+		cached bool
+	}{
+		{"ok", http.StatusOK, nil, true},
+		{"other success", http.StatusNonAuthoritativeInfo, nil, true},
+		{"not found", http.StatusNotFound, ErrNotFound, false},
+		{"blocked", http.StatusForbidden, ErrBlocked, false},
+		{"rate limited", http.StatusTooManyRequests, ErrRateLimited, false},
+		{"server error", http.StatusInternalServerError, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				w.WriteHeader(tt.status)
+				w.Write([]byte("page"))
+			}))
+			defer srv.Close()
+
+			ex, err := NewAniVietSubExtractor(srv.URL,
+				WithHTTPClient(srv.Client()),
+				WithCache(NewCache(t.TempDir(), 0)),
+				WithRetryPolicy(RetryPolicy{}),
+				WithoutWarmUp(),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for range 2 {
+				req, _ := http.NewRequest(http.MethodGet, srv.URL+"/phim/a1/", nil)
+				data, err := ex.fetchCached(CacheDetails, req)
+				if tt.status >= 200 && tt.status <= 299 {
+					if err != nil || string(data) != "page" {
+						t.Fatalf("fetchCached = %q, %v, want the page", data, err)
+					}
+					continue
+				}
+
+				var httpErr *HTTPError
+				if tt.err != nil {
+					if !errors.Is(err, tt.err) {
+						t.Errorf("err = %v, want %v", err, tt.err)
+					}
+				} else if !errors.As(err, &httpErr) {
+					t.Errorf("err = %v, want an *HTTPError", err)
+				}
+				if strings.Contains(fmt.Sprint(err), "network error") {
+					t.Errorf("err = %v, labeled as a network error", err)
+				}
+			}
+
+			want := int32(2)
+			if tt.cached {
+				want = 1
+			}
+			if got := requests.Load(); got != want {
+				t.Errorf("%d requests for two fetches, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestFetchCachedNetworkError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	ex, err := NewAniVietSubExtractor(srv.URL, WithoutWarmUp())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	_, err = ex.fetchCached(CacheDetails, req)
+
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !strings.HasPrefix(err.Error(), "network error: ") {
+		t.Errorf("err = %v, want a network error", err)
+	}
+}
@@ -0,0 +1,184 @@
+package extractor
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const LATEST_PAGE = "/anime-moi"
+const GENRE_PAGE = "/the-loai"
+const YEAR_PAGE = "/nam-phat-hanh"
+const SEASON_PAGE = "/season"
+const SCHEDULE_PAGE = "/lich-chieu-phim.html"
+
+// Genre is an entry of the site's genre menu.
+type Genre struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// ScheduleDay lists the anime airing on one day of the week.
+type ScheduleDay struct {
+	Weekday time.Weekday  `json:"weekday"`
+	Anime   []SimpleAnime `json:"anime"`
+}
+
+// Latest returns a page of the recently updated anime, newest first. The
+// Episode field of each result holds the latest episode label.
+func (ex *AniVietSubExtractor) Latest(page int) (*ListPage, error) {
+	return ex.fetchListing(CacheListing, listingURL(ex.domain, page, LATEST_PAGE), nil)
+}
+
+// ByGenre returns a page of the anime tagged with the genre slug, as listed
+// by Genres.
+func (ex *AniVietSubExtractor) ByGenre(slug string, page int) (*ListPage, error) {
+	if slug == "" {
+		return nil, fmt.Errorf("missing genre")
+	}
+	return ex.fetchListing(CacheListing, listingURL(ex.domain, page, GENRE_PAGE, slug), nil)
+}
+
+// BySeason returns a page of the anime released in year, narrowed down to
+// season (spring, summer, fall or winter) unless it is empty.
+func (ex *AniVietSubExtractor) BySeason(year int, season string, page int) (*ListPage, error) {
+	if year <= 0 {
+		return nil, fmt.Errorf("invalid year %d", year)
+	}
+	if season == "" {
+		return ex.fetchListing(CacheListing, listingURL(ex.domain, page, YEAR_PAGE, strconv.Itoa(year)), nil)
+	}
+
+	param, ok := seasonParams[season]
+	if !ok {
+		return nil, fmt.Errorf("unknown season %q", season)
+	}
+	return ex.fetchListing(CacheListing, listingURL(ex.domain, page, SEASON_PAGE, "mua-"+param, strconv.Itoa(year)), nil)
+}
+
+// Genres returns the genres offered in the site menu.
+func (ex *AniVietSubExtractor) Genres() ([]Genre, error) {
+	content, err := ex.fetchPage(CacheListing, mustJoinPath(ex.domain, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	genres, err := extractGenres(content)
+	if err != nil {
+		return nil, fmt.Errorf("parse error: %w: %w", ErrLayoutChanged, err)
+	}
+	return genres, nil
+}
+
+// Schedule returns the weekly airing schedule, starting on Monday.
+func (ex *AniVietSubExtractor) Schedule() ([]ScheduleDay, error) {
+	content, err := ex.fetchPage(CacheListing, mustJoinPath(ex.domain, SCHEDULE_PAGE))
+	if err != nil {
+		return nil, err
+	}
+
+	days, err := extractSchedule(content)
+	if err != nil {
+		return nil, fmt.Errorf("parse error: %w: %w", ErrLayoutChanged, err)
+	}
+	return days, nil
+}
+
+// fetchPage GETs a metadata page through the cache.
+func (ex *AniVietSubExtractor) fetchPage(kind CacheKind, url string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("setup request: %w", err)
+	}
+	content, err := ex.fetchCached(kind, req)
+	if err != nil {
+		return "", fmt.Errorf("fetch: %w", err)
+	}
+	return string(content), nil
+}
+
+func extractGenres(content string) ([]Genre, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	genres := []Genre{}
+	seen := make(map[string]bool)
+	doc.Find("a[href*='" + GENRE_PAGE + "/']").Each(func(i int, a *goquery.Selection) {
+		// The slug is the path element after the genre page, which also
+		// skips links to the genre index and keeps paginated links.
+		href := a.AttrOr("href", "")
+		slug := href[strings.Index(href, GENRE_PAGE+"/")+len(GENRE_PAGE)+1:]
+		slug, _, _ = strings.Cut(slug, "/")
+		name := strings.TrimSpace(a.Text())
+		if slug == "" || name == "" || seen[slug] {
+			return
+		}
+		seen[slug] = true
+		genres = append(genres, Genre{Name: name, Slug: slug})
+	})
+	if len(genres) == 0 {
+		return nil, fmt.Errorf("no genres in menu")
+	}
+	return genres, nil
+}
+
+// weekdays maps the day headings of the schedule page to weekdays.
+var weekdays = map[string]time.Weekday{
+	"thứ 2":    time.Monday,
+	"thứ hai":  time.Monday,
+	"thứ 3":    time.Tuesday,
+	"thứ ba":   time.Tuesday,
+	"thứ 4":    time.Wednesday,
+	"thứ tư":   time.Wednesday,
+	"thứ 5":    time.Thursday,
+	"thứ năm":  time.Thursday,
+	"thứ 6":    time.Friday,
+	"thứ sáu":  time.Friday,
+	"thứ 7":    time.Saturday,
+	"thứ bảy":  time.Saturday,
+	"chủ nhật": time.Sunday,
+}
+
+func parseWeekday(heading string) (time.Weekday, bool) {
+	lower := strings.ToLower(strings.Join(strings.Fields(heading), " "))
+	for name, day := range weekdays {
+		if strings.Contains(lower, name) {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+// extractSchedule parses the schedule page, where every day is a block
+// with a heading followed by the usual movie cards.
+func extractSchedule(content string) ([]ScheduleDay, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	var days []ScheduleDay
+	doc.Find(".schedule-day").Each(func(i int, s *goquery.Selection) {
+		day, ok := parseWeekday(s.Find(".Title, h2, h3").First().Text())
+		if !ok {
+			return
+		}
+		days = append(days, ScheduleDay{Weekday: day, Anime: extractListItems(s)})
+	})
+	if len(days) == 0 {
+		return nil, fmt.Errorf("no days in schedule")
+	}
+
+	// Monday first, Sunday last, the way the site lays out the week.
+	slices.SortStableFunc(days, func(d1, d2 ScheduleDay) int {
+		return (int(d1.Weekday)+6)%7 - (int(d2.Weekday)+6)%7
+	})
+	return days, nil
+}
@@ -0,0 +1,87 @@
+package extractor
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExtractGenres(t *testing.T) {
+	menu := `<nav><ul class="sub-menu">
+		<li><a href="https://animevietsub.example/the-loai/hanh-dong/">Hành Động</a></li>
+		<li><a href="https://animevietsub.example/the-loai/hai-huoc">
+			Hài Hước
+		</a></li>
+		<li><a href="/the-loai/hanh-dong/trang-2.html">Hành Động</a></li>
+		<li><a href="/the-loai/kinh-di/trang-2.html">Kinh Dị</a></li>
+		<li><a href="/the-loai/">Thể loại</a></li>
+		<li><a href="/the-loai/trong/"></a></li>
+		<li><a href="/nam-phat-hanh/2024/">2024</a></li>
+	</ul></nav>`
+
+	genres, err := extractGenres(menu)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Genre{{Name: "Hành Động", Slug: "hanh-dong"}, {Name: "Hài Hước", Slug: "hai-huoc"}, {Name: "Kinh Dị", Slug: "kinh-di"}}
+	if len(genres) != len(want) {
+		t.Fatalf("genres = %+v, want %+v", genres, want)
+	}
+	for i := range want {
+		if genres[i] != want[i] {
+			t.Errorf("genre %d = %+v, want %+v", i, genres[i], want[i])
+		}
+	}
+
+	if _, err := extractGenres(`<nav></nav>`); err == nil {
+		t.Error("want an error for a page without genres")
+	}
+}
+
+// scheduleCard is a movie card as listed under a day of the schedule.
+func scheduleCard(id int, title string) string {
+	return fmt.Sprintf(`<ul class="MovieList"><li class="TPostMv"><a href="/phim/a%d/"><h2 class="Title">%s</h2></a></li></ul>`, id, title)
+}
+
+func TestExtractSchedule(t *testing.T) {
+	// Days appear out of order, with varying headings, and one block has
+	// no recognizable day.
+	page := `<div class="schedule-day"><h2 class="Title">Chủ Nhật</h2>` + scheduleCard(3, "One Piece") + `</div>
+		<div class="schedule-day"><h3>Thứ  Hai</h3>` + scheduleCard(1, "Fate/Zero") + scheduleCard(2, "Mushishi") + `</div>
+		<div class="schedule-day"><div class="Title">Thứ 5 (hôm nay)</div>` + scheduleCard(4, "Frieren") + `</div>
+		<div class="schedule-day"><h2>Đang cập nhật</h2>` + scheduleCard(5, "Unknown") + `</div>`
+
+	days, err := extractSchedule(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		day    time.Weekday
+		titles string
+	}{
+		{time.Monday, "Fate/Zero,Mushishi"},
+		{time.Thursday, "Frieren"},
+		{time.Sunday, "One Piece"},
+	}
+	if len(days) != len(want) {
+		t.Fatalf("got %d days, want %d: %+v", len(days), len(want), days)
+	}
+	for i, w := range want {
+		var titles []string
+		for _, a := range days[i].Anime {
+			titles = append(titles, a.Title)
+		}
+		if days[i].Weekday != w.day || strings.Join(titles, ",") != w.titles {
+			t.Errorf("day %d = %v %v, want %v [%s]", i, days[i].Weekday, titles, w.day, w.titles)
+		}
+	}
+	if id := days[0].Anime[1].Id; id != 2 {
+		t.Errorf("id = %d, want 2", id)
+	}
+
+	if _, err := extractSchedule(`<div class="schedule-day"><h2>Sắp chiếu</h2></div>`); err == nil {
+		t.Error("want an error for a page without days")
+	}
+}
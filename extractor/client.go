@@ -2,9 +2,11 @@ package extractor
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
@@ -20,78 +22,118 @@ import (
 const USER_AGENT = "Mozilla/5.0 (iPhone; CPU iPhone OS 16_1_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) GSA/383.0.797833943 Mobile/15E148 Safari/604.1"
 const SEARCH_API = "/ajax/suggest"
 const PLAYLIST_API = "/ajax/player"
-const TRENDING_API = "/bang-xep-hang/season.html"
-
-const (
-	maxRetries = 3
-	retryDelay = 2 * time.Second
-)
+const TRENDING_API = "/bang-xep-hang"
+const DOMAIN_RESOLVER = "https://bit.ly/animevietsubtv"
 
 type AniVietSubExtractor struct {
-	domain      string
-	client      *http.Client
-	jar         *cookiejar.Jar
-	useAdaptive bool
+	domain    string
+	client    *http.Client
+	userAgent string
+	logger    *slog.Logger
+	retry     RetryPolicy
+	strategy  Strategy
+	cache     *Cache
 }
 
-func NewAniVietSubExtractor(domain string) (*AniVietSubExtractor, error) {
-
-	// Init cookie jar (uses publicsuffix to handle domain scoping correctly)
-	jar, err := cookiejar.New(&cookiejar.Options{
-		PublicSuffixList: publicsuffix.List,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to create cookie jar: %w", err)
+func NewAniVietSubExtractor(domain string, opts ...Option) (*AniVietSubExtractor, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
 	}
 
-	tlsConfig := &tls.Config{
-		MinVersion:         tls.VersionTLS12,
-		MaxVersion:         tls.VersionTLS13,
-		InsecureSkipVerify: false,
-		CipherSuites: []uint16{
-			tls.TLS_AES_128_GCM_SHA256,
-			tls.TLS_AES_256_GCM_SHA384,
-			tls.TLS_CHACHA20_POLY1305_SHA256,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
-			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
-			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
-		},
+	client, err := o.buildClient()
+	if err != nil {
+		return nil, err
 	}
-
-	transport := &http.Transport{
-		TLSClientConfig: tlsConfig,
+	if client.Jar == nil {
+		// Init cookie jar (uses publicsuffix to handle domain scoping correctly)
+		jar, err := cookiejar.New(&cookiejar.Options{
+			PublicSuffixList: publicsuffix.List,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cookie jar: %w", err)
+		}
+		client.Jar = jar
 	}
 
-	client := &http.Client{
-		Timeout:   20 * time.Second,
-		Transport: transport,
-		Jar:       jar,
+	ex := &AniVietSubExtractor{
+		domain:    domain,
+		client:    client,
+		userAgent: o.userAgent,
+		logger:    o.logger,
+		retry:     o.retry,
+		strategy:  o.strategy,
+		cache:     o.cache,
 	}
 
 	// Auto resolve domain if not provided
 	if domain == "" {
-		resp, err := http.Get("https://bit.ly/animevietsubtv")
+		resolved, err := ex.resolveDomain()
 		if err != nil {
 			return nil, fmt.Errorf("can't auto resolve animevietsub domain: %w", err)
 		}
-		domain = resp.Request.URL.String()
+		ex.domain = resolved
 	}
 
-	ex := &AniVietSubExtractor{
-		client: client,
-		domain: domain,
-		jar:    jar,
+	// Fetch homepage to get Cloudflare cookies before any real request,
+	// unless the clearance from a previous run is still valid
+	if !o.skipWarmUp && !ex.hasClearance() {
+		if err := ex.warmUp(); err != nil {
+			return nil, fmt.Errorf("warmup failed: %w", err)
+		}
 	}
 
-	// Fetch homepage to get Cloudflare cookies before any real request
-	if err := ex.warmUp(); err != nil {
-		return nil, fmt.Errorf("warmup failed: %w", err)
+	return ex, nil
+}
+
+// resolveDomain follows the bit.ly short link to the site's current domain.
+func (ex *AniVietSubExtractor) resolveDomain() (string, error) {
+	req, err := http.NewRequest(http.MethodGet, DOMAIN_RESOLVER, nil)
+	if err != nil {
+		return "", err
 	}
+	req.Header.Set("User-Agent", ex.userAgent)
 
-	return ex, nil
+	resp, err := ex.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	return resp.Request.URL.String(), nil
+}
+
+// clearanceCookie is the cookie Cloudflare sets once a client passed its
+// challenge.
+const clearanceCookie = "cf_clearance"
+
+// clearanceMargin is how long a clearance cookie must still be valid to be
+// reused, so it does not expire in the middle of the run.
+const clearanceMargin = 5 * time.Minute
+
+// cookieExpirer is implemented by jars that know when their cookies
+// expire, such as PersistentJar.
+type cookieExpirer interface {
+	Expiry(u *url.URL, name string) (time.Time, bool)
+}
+
+// hasClearance reports whether the jar holds a Cloudflare clearance cookie
+// for the site that stays valid for a while.
+func (ex *AniVietSubExtractor) hasClearance() bool {
+	u, err := url.Parse(ex.domain)
+	if err != nil {
+		return false
+	}
+	if !slices.ContainsFunc(ex.client.Jar.Cookies(u), func(c *http.Cookie) bool { return c.Name == clearanceCookie }) {
+		return false
+	}
+	if jar, ok := ex.client.Jar.(cookieExpirer); ok {
+		if expires, ok := jar.Expiry(u, clearanceCookie); ok {
+			return time.Until(expires) > clearanceMargin
+		}
+	}
+	return true
 }
 
 func (ex *AniVietSubExtractor) warmUp() error {
@@ -126,10 +168,12 @@ func (ex *AniVietSubExtractor) doWithRetry(req *http.Request) (*http.Response, e
 
 	ex.setCommonHeaders(req)
 
-	for attempt := 0; attempt <= maxRetries; attempt++ {
+	for attempt := 0; attempt <= ex.retry.MaxRetries; attempt++ {
 		if attempt > 0 {
-			fmt.Printf("403 received, retrying (%d/%d) after warm-up...\n", attempt, maxRetries)
-			time.Sleep(retryDelay)
+			ex.logger.Warn("403 received, retrying after warm-up", "attempt", attempt, "max", ex.retry.MaxRetries, "url", req.URL.String())
+			if err := sleepContext(req.Context(), ex.retry.Delay); err != nil {
+				return nil, err
+			}
 
 			if err := ex.warmUp(); err != nil {
 				return nil, fmt.Errorf("warm-up failed on retry: %w", err)
@@ -153,14 +197,16 @@ func (ex *AniVietSubExtractor) doWithRetry(req *http.Request) (*http.Response, e
 		resp.Body.Close()
 	}
 
-	return nil, fmt.Errorf("request failed with 403 after %d retries", maxRetries)
+	return nil, fmt.Errorf("request to %s failed with 403 after %d retries: %w", req.URL, ex.retry.MaxRetries, ErrBlocked)
 }
 
 func (ex *AniVietSubExtractor) setCommonHeaders(req *http.Request) {
 	if req.Header.Get("User-Agent") == "" {
-		req.Header.Set("User-Agent", USER_AGENT)
+		req.Header.Set("User-Agent", ex.userAgent)
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
 	}
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
 	req.Header.Set("Sec-Fetch-Dest", "empty")
 	req.Header.Set("Sec-Fetch-Mode", "same-origin")
 	req.Header.Set("Accept-Language", "vi-VN,vi;q=0.9,en-US;q=0.8,en;q=0.7")
@@ -180,19 +226,14 @@ func (ex *AniVietSubExtractor) Search(query string) ([]SimpleAnime, error) {
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	r, err := ex.doWithRetry(req)
+	content, err := ex.fetchCached(CacheSearch, req)
 	if err != nil {
-		return nil, fmt.Errorf("network error: %s", err)
-	}
-	defer r.Body.Close()
-
-	if r.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("api error: %s", r.Status)
+		return nil, fmt.Errorf("search: %w", err)
 	}
 
-	movies, err := extractMovies(r.Body)
+	movies, err := extractMovies(bytes.NewReader(content))
 	if err != nil {
-		return nil, fmt.Errorf("parse error: %s", err)
+		return nil, fmt.Errorf("parse error: %w: %w", ErrLayoutChanged, err)
 	}
 
 	return movies, nil
@@ -206,48 +247,49 @@ func (ex *AniVietSubExtractor) GetAnimeDetails(id int) (*AnimeDetail, error) {
 		return nil, err
 	}
 
-	r, err := ex.doWithRetry(req)
+	bodyBytes, err := ex.fetchCached(CacheDetails, req)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("anime %d: %w", id, err)
 	}
 
-	bodyBytes, err := io.ReadAll(r.Body)
-	r.Body.Close()
+	details, err := parseAnimeVietsubAnimeDetails(id, bytes.NewReader(bodyBytes))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("parse anime %d: %w: %w", id, ErrLayoutChanged, err)
+	}
+	if details.Title == "" && len(details.Episodes) == 0 {
+		return nil, fmt.Errorf("anime %d: %w", id, ErrNotFound)
 	}
 
-	return parseAnimeVietsubAnimeDetails(id, bytes.NewReader(bodyBytes))
+	return details, nil
 }
 
-func (ex *AniVietSubExtractor) Trending() ([]SimpleAnime, error) {
-	api := mustJoinPath(ex.domain, TRENDING_API)
+// Trending returns the site ranking for period, most viewed first.
+func (ex *AniVietSubExtractor) Trending(period Period) ([]RankedAnime, error) {
+	if period == "" {
+		period = PeriodSeason
+	}
+	api := mustJoinPath(ex.domain, TRENDING_API, string(period)+".html")
 
 	req, err := http.NewRequest(http.MethodGet, api, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	r, err := ex.doWithRetry(req)
+	content, err := ex.fetchCached(CacheTrending, req)
 	if err != nil {
-		return nil, fmt.Errorf("network error: %s", err)
-	}
-	defer r.Body.Close()
-
-	if r.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("api error: %s", r.Status)
+		return nil, fmt.Errorf("trending: %w", err)
 	}
 
-	movies, err := extractTrendingMovies(r.Body)
+	movies, err := extractTrendingMovies(bytes.NewReader(content))
 	if err != nil {
-		return nil, fmt.Errorf("parse error: %s", err)
+		return nil, fmt.Errorf("parse error: %w: %w", ErrLayoutChanged, err)
 	}
 
 	return movies, nil
 }
 
-func (ex *AniVietSubExtractor) fetchHtml(url string) (string, error) {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+func (ex *AniVietSubExtractor) fetchHtml(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return "", fmt.Errorf("setup request: %w", err)
 	}
@@ -257,6 +299,10 @@ func (ex *AniVietSubExtractor) fetchHtml(url string) (string, error) {
 	}
 	defer r.Body.Close()
 
+	if r.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch: %w", newHTTPError(r))
+	}
+
 	content, err := io.ReadAll(r.Body)
 	if err != nil {
 		return "", fmt.Errorf("read body: %w", err)
@@ -266,7 +312,11 @@ func (ex *AniVietSubExtractor) fetchHtml(url string) (string, error) {
 }
 
 func (ex *AniVietSubExtractor) GetM3UPlaylist(e Episode) ([]byte, error) {
-	rawEpisode, err := ex.fetchHtml(e.Href)
+	return ex.getM3UPlaylist(context.Background(), e)
+}
+
+func (ex *AniVietSubExtractor) getM3UPlaylist(ctx context.Context, e Episode) ([]byte, error) {
+	rawEpisode, err := ex.fetchHtml(ctx, e.Href)
 	if err != nil {
 		return nil, fmt.Errorf("fetch episode: %w", err)
 	}
@@ -276,7 +326,7 @@ func (ex *AniVietSubExtractor) GetM3UPlaylist(e Episode) ([]byte, error) {
 		return nil, fmt.Errorf("extract playlist link: %w", err)
 	}
 
-	playerHtml, err := ex.fetchHtml(playerLink.String())
+	playerHtml, err := ex.fetchHtml(ctx, playerLink.String())
 	if err != nil {
 		return nil, fmt.Errorf("fetch player: %w", err)
 	}
@@ -289,7 +339,7 @@ func (ex *AniVietSubExtractor) GetM3UPlaylist(e Episode) ([]byte, error) {
 	origin := fmt.Sprint(playerLink.Scheme, "://", playerLink.Host)
 	playlistURL := fmt.Sprintf("%s/playlist/%s/playlist.m3u8?token=%s", origin, playerData.VideoID, playerData.AVSToken)
 
-	body, headers, err := ex.fetchPlaylist(playlistURL)
+	body, headers, err := ex.fetchPlaylist(ctx, playlistURL)
 	if err != nil {
 		return nil, fmt.Errorf("fetch playlist: %w", err)
 	}
@@ -297,14 +347,50 @@ func (ex *AniVietSubExtractor) GetM3UPlaylist(e Episode) ([]byte, error) {
 	envelope := extractEnvelope(headers)
 	playlist, err := decryptPlaylist(body, &envelope, playerData.AVSToken, origin)
 	if err != nil {
-		return nil, fmt.Errorf("decrypt playlist: %w", err)
+		return nil, fmt.Errorf("decrypt playlist: %w: %w", ErrDecrypt, err)
 	}
 
 	return playlist, nil
 }
 
-func (ex *AniVietSubExtractor) fetchPlaylist(playlistURL string) ([]byte, http.Header, error) {
-	req, err := http.NewRequest(http.MethodGet, playlistURL, nil)
+// FetchImage downloads a poster, banner or thumbnail URL scraped from the
+// site. Relative URLs are resolved against the site domain.
+func (ex *AniVietSubExtractor) FetchImage(imageURL string) ([]byte, error) {
+	return ex.FetchImageContext(context.Background(), imageURL)
+}
+
+// FetchImageContext is like FetchImage but gives up as soon as ctx is done.
+// Requests blocked by Cloudflare are retried like page requests.
+func (ex *AniVietSubExtractor) FetchImageContext(ctx context.Context, imageURL string) ([]byte, error) {
+	base, err := url.Parse(ex.domain)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := url.Parse(imageURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image url: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base.ResolveReference(ref).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "image/avif,image/webp,image/*,*/*;q=0.8")
+
+	resp, err := ex.doWithRetry(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch image: %w", newHTTPError(resp))
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (ex *AniVietSubExtractor) fetchPlaylist(ctx context.Context, playlistURL string) ([]byte, http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, playlistURL, nil)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -317,7 +403,7 @@ func (ex *AniVietSubExtractor) fetchPlaylist(playlistURL string) ([]byte, http.H
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, nil, fmt.Errorf("playlist fetch status: %d", resp.StatusCode)
+		return nil, nil, fmt.Errorf("playlist fetch: %w", newHTTPError(resp))
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -328,34 +414,211 @@ func (ex *AniVietSubExtractor) fetchPlaylist(playlistURL string) ([]byte, http.H
 	return body, resp.Header, nil
 }
 
-func (ex *AniVietSubExtractor) Download(e Episode, w io.Writer, callback func(progress float64)) error {
-	playlist, err := ex.GetM3UPlaylist(e)
+func (ex *AniVietSubExtractor) Download(e Episode, w io.Writer, callback func(p Progress)) error {
+	return ex.DownloadContext(context.Background(), e, w, callback)
+}
+
+// DownloadContext is like Download but stops as soon as ctx is done, in
+// which case the returned error wraps ctx.Err() and w holds only the
+// segments written so far.
+//
+// Segment URLs carry a token that expires after a while. When segments
+// start failing with 403 or 410, the playlist is extracted again and the
+// download continues with fresh URLs from the first missing segment.
+func (ex *AniVietSubExtractor) DownloadContext(ctx context.Context, e Episode, w io.Writer, callback func(p Progress)) error {
+	playlist, err := ex.getM3UPlaylist(ctx, e)
 	if err != nil {
 		return err
 	}
-	segmentURLs := extractSegmentURLs(playlist)
-	if len(segmentURLs) == 0 {
-		return fmt.Errorf("no segment URLs found in playlist")
+	segments := extractSegments(playlist)
+	if len(segments) == 0 {
+		return fmt.Errorf("no segment URLs found in playlist: %w", ErrLayoutChanged)
 	}
 
-	var downloader SegmentDownloader
-	if ex.useAdaptive {
-		downloader = newAdaptiveDownloader(ex.client, ex.domain)
-	} else {
-		downloader = newGreedyDownloader(ex.client, ex.domain)
+	downloader := newSegmentDownloader(ex.strategy, ex.segmentFetcher())
+	return downloadPlaylist(ctx, downloader, segments, w, callback, func(ctx context.Context, segments []playlistSegment, done int) ([]playlistSegment, error) {
+		ex.logger.Info("segment URLs expired, refreshing playlist", "episode", e.Id, "segment", done+1)
+		return ex.refreshSegments(ctx, e, segments, done)
+	})
+}
+
+// downloadPlaylist writes segments to w with downloader and reports the
+// progress to callback after every segment. When segment URLs expire,
+// refresh is asked for the segments again, the first done of them already
+// written, and the download continues from there.
+func downloadPlaylist(ctx context.Context, downloader SegmentDownloader, segments []playlistSegment, w io.Writer, callback func(p Progress), refresh func(ctx context.Context, segments []playlistSegment, done int) ([]playlistSegment, error)) error {
+	progress := Progress{Segments: len(segments), Duration: totalDuration(segments)}
+	refreshedAt := -1
+	for {
+		// Every round gets its own context, so requests still in flight when
+		// a round fails stop before the next one starts.
+		roundCtx, cancel := context.WithCancel(ctx)
+		done := progress.Segment
+		err := downloader.downloadSegments(roundCtx, segments[done:], w, func(index int, segment *fetchedSegment) {
+			index += done
+			progress.Segment = index + 1
+			progress.Bytes += int64(len(segment.data))
+			progress.Position += segments[index].Duration
+			if segment.refetches > 0 {
+				progress.Refetched++
+			}
+			if segment.damage != nil {
+				progress.Damaged = append(progress.Damaged, SegmentError{Index: index, Err: segment.damage})
+			}
+			if callback != nil {
+				callback(progress)
+			}
+		})
+		cancel()
+		// Refresh at most once per position, so URLs that keep failing
+		// right away are reported instead of refreshed forever.
+		if err == nil || !isTokenExpired(err) || progress.Segment == refreshedAt {
+			return err
+		}
+		refreshedAt = progress.Segment
+
+		fresh, refreshErr := refresh(ctx, segments, progress.Segment)
+		if refreshErr != nil {
+			return fmt.Errorf("%w (refreshing the playlist failed: %w)", err, refreshErr)
+		}
+		segments = fresh
+		progress.Segments = len(segments)
+		progress.Duration = totalDuration(segments)
+	}
+}
+
+// DownloadSegment fetches a single segment URL of a playlist returned by
+// GetM3UPlaylist and returns its MPEG-TS payload, unwrapped from the PNG
+// envelope the CDN serves it in.
+func (ex *AniVietSubExtractor) DownloadSegment(url string) ([]byte, error) {
+	return ex.DownloadSegmentContext(context.Background(), url, "")
+}
+
+// DownloadSegmentContext is like DownloadSegment with a context and an
+// optional player token. The token is only needed for segment URLs still in
+// their encrypted /hls/<id>.ts?e=... form; playlists from GetM3UPlaylist
+// already carry decrypted URLs.
+//
+// Rate limited requests are retried with backoff and corrupt payloads are
+// fetched again, as during Download. A payload that stays corrupt is
+// reported as an error.
+func (ex *AniVietSubExtractor) DownloadSegmentContext(ctx context.Context, segmentURL string, token string) ([]byte, error) {
+	if u, err := url.Parse(segmentURL); err == nil && ENCRYPTED_SEGMENT.MatchString(u.Path) && u.Query().Has("e") {
+		if token == "" {
+			return nil, fmt.Errorf("encrypted segment URL needs a player token: %w", ErrDecrypt)
+		}
+		decrypted, err := decryptSegmentURL(segmentURL, token)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt segment URL: %w: %w", ErrDecrypt, err)
+		}
+		segmentURL = decrypted
+	}
+
+	downloader := newGreedyDownloader(ex.segmentFetcher())
+	segment, err := fetchVerified(ctx, playlistSegment{URL: segmentURL}, downloader.fetch)
+	if err != nil {
+		return nil, err
+	}
+	if segment.damage != nil {
+		return nil, fmt.Errorf("corrupt segment: %w", segment.damage)
+	}
+	return segment.data, nil
+}
+
+// segmentFetcher returns a fetcher sending segment requests with the
+// headers the CDN expects.
+func (ex *AniVietSubExtractor) segmentFetcher() *segmentFetcher {
+	return &segmentFetcher{
+		client:    ex.client,
+		referer:   ex.domain,
+		userAgent: ex.userAgent,
+	}
+}
+
+// isTokenExpired reports whether err comes from a segment request rejected
+// because the token in its URL is no longer valid.
+func isTokenExpired(err error) bool {
+	var httpErr *HTTPError
+	return errors.As(err, &httpErr) &&
+		(httpErr.StatusCode == http.StatusForbidden || httpErr.StatusCode == http.StatusGone)
+}
+
+// refreshSegments extracts the playlist of e again and returns segments
+// with everything after the first done ones replaced by their fresh URLs.
+func (ex *AniVietSubExtractor) refreshSegments(ctx context.Context, e Episode, segments []playlistSegment, done int) ([]playlistSegment, error) {
+	playlist, err := ex.getM3UPlaylist(ctx, e)
+	if err != nil {
+		return nil, err
+	}
+	rest, err := remapSegments(segments, extractSegments(playlist), done)
+	if err != nil {
+		return nil, err
+	}
+	return append(segments[:done:done], rest...), nil
+}
+
+// remapSegments returns the segments of fresh that follow the first done
+// segments of old. Playlists of the same length are matched by index,
+// otherwise the fresh segment starting where the downloaded ones end is
+// looked up by time.
+func remapSegments(old, fresh []playlistSegment, done int) ([]playlistSegment, error) {
+	if len(fresh) == 0 {
+		return nil, fmt.Errorf("no segment URLs found in playlist: %w", ErrLayoutChanged)
 	}
-	return downloader.downloadSegments(segmentURLs, w, callback)
+	if len(fresh) == len(old) {
+		return fresh[done:], nil
+	}
+
+	position := totalDuration(old[:done])
+	var start time.Duration
+	for i, s := range fresh {
+		tolerance := min(s.Duration/2, 250*time.Millisecond)
+		if diff := start - position; diff >= -tolerance && diff <= tolerance {
+			return fresh[i:], nil
+		}
+		start += s.Duration
+	}
+	if diff := start - position; diff >= -250*time.Millisecond && diff <= 250*time.Millisecond {
+		return nil, nil // everything was downloaded already
+	}
+	return nil, fmt.Errorf("refreshed playlist has no segment starting at %s", position)
+}
+
+func totalDuration(segments []playlistSegment) time.Duration {
+	var d time.Duration
+	for _, s := range segments {
+		d += s.Duration
+	}
+	return d
+}
+
+// playlistSegment is a media segment of an HLS playlist.
+type playlistSegment struct {
+	URL      string
+	Duration time.Duration
 }
-func extractSegmentURLs(playlist []byte) []string {
+
+// extractSegments lists the segment URLs of playlist together with the
+// duration announced by the preceding #EXTINF tag.
+func extractSegments(playlist []byte) []playlistSegment {
 	lines := strings.Split(string(playlist), "\n")
-	urls := make([]string, 0, len(lines)/2)
+	segments := make([]playlistSegment, 0, len(lines)/2)
+	var duration time.Duration
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
+		if rest, ok := strings.CutPrefix(line, "#EXTINF:"); ok {
+			value, _, _ := strings.Cut(rest, ",")
+			if secs, err := strconv.ParseFloat(value, 64); err == nil {
+				duration = time.Duration(secs * float64(time.Second))
+			}
+			continue
+		}
 		if strings.HasPrefix(line, "http") {
-			urls = append(urls, line)
+			segments = append(segments, playlistSegment{URL: line, Duration: duration})
+			duration = 0
 		}
 	}
-	return urls
+	return segments
 }
 
 func extractMovies(r io.Reader) ([]SimpleAnime, error) {
@@ -376,23 +639,31 @@ func extractMovies(r io.Reader) ([]SimpleAnime, error) {
 	return movies, nil
 }
 
-func extractTrendingMovies(r io.Reader) ([]SimpleAnime, error) {
+func extractTrendingMovies(r io.Reader) ([]RankedAnime, error) {
 	doc, err := goquery.NewDocumentFromReader(r)
 	if err != nil {
 		return nil, err
 	}
-	movies := []SimpleAnime{}
+	movies := []RankedAnime{}
 	doc.Find("ul.bxh-movie-phimletv li").Each(func(i int, s *goquery.Selection) {
 		a := s.Find("h3.title-item a")
-		title := a.Text()
+		title := strings.TrimSpace(a.Text())
 		href := a.AttrOr("href", "")
-		thumbnail := s.Find("a.thumb img").AttrOr("src", "")
+		thumbnail := imageSource(s.Find("a.thumb img"))
 		if title != "" && href != "" {
-			movies = append(movies, SimpleAnime{
-				Id:        extractLargestNumber(href),
-				Title:     title,
-				Href:      href,
-				Thumbnail: thumbnail,
+			rank, err := strconv.Atoi(strings.TrimSpace(s.Find(".number-rank").First().Text()))
+			if err != nil {
+				rank = len(movies) + 1
+			}
+			movies = append(movies, RankedAnime{
+				SimpleAnime: SimpleAnime{
+					Id:        extractLargestNumber(href),
+					Title:     title,
+					Href:      href,
+					Thumbnail: thumbnail,
+				},
+				Rank:  rank,
+				Views: parseViews(s.Find(".view").First().Text()),
 			})
 		}
 	})
@@ -410,38 +681,26 @@ func parseAnimeVietsubAnimeDetails(movieId int, r io.Reader) (*AnimeDetail, erro
 	var episodes []Episode
 	episodeListTag := doc.Find("#list-server").First()
 	episodeListTag.Find("li.episode>a.btn-episode").Each(func(i int, s *goquery.Selection) {
+		title := s.AttrOr("title", "")
+		number, kind, numbered := parseEpisodeTitle(title)
 		episodes = append(episodes, Episode{
-			MovieId: movieId,
-			Id:      s.AttrOr("data-id", ""),
-			Title:   s.AttrOr("title", ""),
-			Href:    s.AttrOr("href", ""),
-			Hash:    s.AttrOr("data-hash", ""),
+			MovieId:  movieId,
+			Id:       s.AttrOr("data-id", ""),
+			Title:    title,
+			Href:     s.AttrOr("href", ""),
+			Hash:     s.AttrOr("data-hash", ""),
+			Number:   number,
+			Numbered: numbered,
+			Kind:     kind,
 		})
 	})
-	slices.SortFunc(episodes, func(e1, e2 Episode) int {
-		id1, err1 := strconv.ParseInt(e1.Id, 10, 64)
-		id2, err2 := strconv.ParseInt(e2.Id, 10, 64)
-
-		// If either ID is invalid, treat them as equal (incomparable).
-		if err1 != nil || err2 != nil {
-			return 0
-		}
-
-		switch {
-		case id1 < id2:
-			return -1
-		case id1 > id2:
-			return 1
-		default:
-			return 0
-		}
-	})
+	sortEpisodes(episodes)
 
 	articleTag := doc.Find("article.TPost")
 	title := strings.TrimSpace(articleTag.Find("h1.Title").Text())
 	subtitle := strings.TrimSpace(articleTag.Find("h2.SubTitle").Text())
 	description := strings.TrimSpace(articleTag.Find("div.Description").Text())
-	accessTime := strings.TrimSpace(articleTag.Find("span.Time").Text())
+	runtime := strings.TrimSpace(articleTag.Find("span.Time").Text())
 	views := strings.TrimSpace(strings.SplitN(articleTag.Find("span.View").Text(), " ", 2)[0])
 
 	scoreStr := strings.TrimSpace(articleTag.Find("#TPVotes").AttrOr("data-percent", "0"))
@@ -450,15 +709,81 @@ func parseAnimeVietsubAnimeDetails(movieId int, r io.Reader) (*AnimeDetail, erro
 		rating = rv / 10
 	}
 
+	poster := imageSource(articleTag.Find(".Image img").First())
+	if poster == "" {
+		poster = doc.Find("meta[property='og:image']").First().AttrOr("content", "")
+	}
+	banner := imageSource(doc.Find(".TPostBg img, img.TPostBg").First())
+
+	info := infoList(doc)
+	status := infoText(info, "trạng thái", "status")
+	season, year := parseSeason(infoText(info, "season", "mùa"))
+	if y := parseYear(articleTag.Find("span.Date").Text()); y != 0 {
+		year = y
+	}
+	if year == 0 {
+		year = parseYear(infoText(info, "năm", "year"))
+	}
+
+	// The header shows either the episode runtime or an "aired/total"
+	// count depending on the anime; the info box has both spelled out.
+	var duration string
+	var totalEpisodes int
+	for _, text := range []string{infoText(info, "thời lượng", "duration"), runtime, status} {
+		switch {
+		case isDuration(text):
+			if duration == "" {
+				duration = text
+			}
+		case totalEpisodes == 0:
+			totalEpisodes = parseEpisodeCount(text)
+		}
+	}
+	parsedStatus := parseStatus(status)
+	if totalEpisodes == 0 && parsedStatus == StatusCompleted {
+		totalEpisodes = len(episodes)
+	}
+
+	var studio string
+	if studios := infoLinks(info, "studio", "hãng sản xuất"); len(studios) > 0 {
+		studio = strings.Join(studios, ", ")
+	} else {
+		studio = infoText(info, "studio", "hãng sản xuất")
+	}
+
+	var related []SimpleAnime
+	doc.Find(".season_item a").Each(func(i int, s *goquery.Selection) {
+		href := s.AttrOr("href", "")
+		id := extractLargestNumber(href)
+		if id == 0 || id == movieId {
+			return
+		}
+		related = append(related, SimpleAnime{
+			Id:    id,
+			Title: strings.TrimSpace(s.Text()),
+			Href:  href,
+		})
+	})
+
 	return &AnimeDetail{
 		Id:            movieId,
 		Title:         title,
 		Subtitle:      subtitle,
+		AltTitles:     splitTitles(infoText(info, "tên khác", "other name")),
 		Description:   description,
 		Rating:        rating,
 		Href:          href,
-		TotalEpisodes: accessTime,
+		Poster:        poster,
+		Banner:        banner,
+		Genres:        infoLinks(info, "thể loại", "genre"),
+		Studio:        studio,
+		Year:          year,
+		Season:        season,
+		Status:        parsedStatus,
+		TotalEpisodes: totalEpisodes,
+		Duration:      duration,
 		Episodes:      episodes,
 		Views:         views,
+		Related:       related,
 	}, nil
 }
@@ -0,0 +1,339 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ppvan/nem/mpegts"
+)
+
+// uniformSegments returns n segments of duration each, with URLs naming
+// their index and the playlist they came from.
+func uniformSegments(prefix string, n int, duration time.Duration) []playlistSegment {
+	segments := make([]playlistSegment, n)
+	for i := range segments {
+		segments[i] = playlistSegment{URL: fmt.Sprintf("%s/%d", prefix, i), Duration: duration}
+	}
+	return segments
+}
+
+func segmentsOf(prefix string, durations ...time.Duration) []playlistSegment {
+	segments := make([]playlistSegment, len(durations))
+	for i, d := range durations {
+		segments[i] = playlistSegment{URL: fmt.Sprintf("%s/%d", prefix, i), Duration: d}
+	}
+	return segments
+}
+
+func TestRemapSegments(t *testing.T) {
+	const s = time.Second
+	old := uniformSegments("old", 6, 2*s)
+	tests := []struct {
+		name  string
+		fresh []playlistSegment
+		done  int
+		first string // URL of the first remaining segment, "" for none
+		err   string
+	}{
+		{"equal length", uniformSegments("new", 6, 2*s), 2, "new/2", ""},
+		{"equal length with other durations", uniformSegments("new", 6, 3*s), 4, "new/4", ""},
+		{"nothing done", segmentsOf("new", 3*s, 3*s, 3*s, 3*s), 0, "new/0", ""},
+		{"same start", segmentsOf("new", 1*s, 3*s, 4*s, 4*s), 2, "new/2", ""},
+		{"shifted later within tolerance", segmentsOf("new", 2200*time.Millisecond, 2*s, 2*s, 2*s, 2*s, 1800*time.Millisecond, s), 2, "new/2", ""},
+		{"shifted earlier within tolerance", segmentsOf("new", 1800*time.Millisecond, 2*s, 2*s, 2*s, 2*s, 2*s, 200*time.Millisecond), 2, "new/2", ""},
+		{"shifted past tolerance", segmentsOf("new", 1600*time.Millisecond, 2*s, 2*s, 2*s, 2*s, 2*s, 400*time.Millisecond), 2, "", "no segment starting at 4s"},
+		{"no match", uniformSegments("new", 4, 3*s), 2, "", "no segment starting at 4s"},
+		{"everything downloaded", segmentsOf("new", 4*s, 4*s, 4100*time.Millisecond), 6, "", ""},
+		{"longer than downloaded", segmentsOf("new", 4*s, 4*s, 4*s, 4*s), 6, "new/3", ""},
+		{"empty", nil, 2, "", ErrLayoutChanged.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rest, err := remapSegments(old, tt.fresh, tt.done)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.first == "" {
+				if len(rest) != 0 {
+					t.Errorf("rest = %v, want none", rest)
+				}
+				return
+			}
+			if len(rest) == 0 || rest[0].URL != tt.first {
+				t.Errorf("rest = %v, want it to start at %s", rest, tt.first)
+			}
+			if last := tt.fresh[len(tt.fresh)-1]; rest[len(rest)-1] != last {
+				t.Errorf("rest ends at %v, want the end of the fresh playlist", rest[len(rest)-1])
+			}
+		})
+	}
+}
+
+func TestIsTokenExpired(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{&HTTPError{StatusCode: http.StatusForbidden}, true},
+		{&HTTPError{StatusCode: http.StatusGone}, true},
+		{fmt.Errorf("failed to download segment 3/10: %w", &HTTPError{StatusCode: http.StatusForbidden}), true},
+		{&HTTPError{StatusCode: http.StatusNotFound}, false},
+		{&HTTPError{StatusCode: http.StatusTooManyRequests}, false},
+		{&HTTPError{StatusCode: http.StatusInternalServerError}, false},
+		{ErrBlocked, false},
+		{context.Canceled, false},
+	}
+	for _, tt := range tests {
+		if got := isTokenExpired(tt.err); got != tt.want {
+			t.Errorf("isTokenExpired(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// segmentDuration is short enough for tsSegment to pass as a whole segment.
+const segmentDuration = 50 * time.Millisecond
+
+// expiringServer serves segments at /old/<index> and /new/<index>. Old URLs
+// from expireAt on answer 403, the last of them only once their request is
+// cancelled or after a while.
+type expiringServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	canceled map[int]bool
+}
+
+func newExpiringServer(t *testing.T, expireAt int, freshExpire func(index int) bool) *expiringServer {
+	s := &expiringServer{canceled: make(map[int]bool)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix, number, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		index, err := strconv.Atoi(number)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		switch {
+		case prefix == "old" && index == expireAt:
+			// Let the segments before it finish first.
+			time.Sleep(50 * time.Millisecond)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		case prefix == "old" && index > expireAt:
+			select {
+			case <-r.Context().Done():
+				s.mu.Lock()
+				s.canceled[index] = true
+				s.mu.Unlock()
+			case <-time.After(2 * time.Second):
+			}
+			w.WriteHeader(http.StatusForbidden)
+			return
+		case prefix == "new" && freshExpire != nil && freshExpire(index):
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		w.Write(pngEnvelope(tsSegment(index)))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// waitCanceled reports whether the request for old segment index is
+// cancelled within timeout.
+func (s *expiringServer) waitCanceled(index int, timeout time.Duration) bool {
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		s.mu.Lock()
+		canceled := s.canceled[index]
+		s.mu.Unlock()
+		if canceled {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *expiringServer) fetcher() *segmentFetcher {
+	return &segmentFetcher{client: s.Client(), referer: s.URL, userAgent: USER_AGENT}
+}
+
+func TestDownloadPlaylistRefreshesExpiredURLs(t *testing.T) {
+	const n = 6
+	srv := newExpiringServer(t, 2, nil)
+	old := uniformSegments(srv.URL+"/old", n, segmentDuration)
+	fresh := uniformSegments(srv.URL+"/new", n, segmentDuration)
+
+	var refreshedAt []int
+	var buf bytes.Buffer
+	var last Progress
+	err := downloadPlaylist(context.Background(), newParallelDownloader(srv.fetcher(), 4), old, &buf, func(p Progress) {
+		last = p
+	}, func(ctx context.Context, segments []playlistSegment, done int) ([]playlistSegment, error) {
+		refreshedAt = append(refreshedAt, done)
+		// The request of the failed round still in flight must be
+		// cancelled, not left to run next to the new round.
+		if !srv.waitCanceled(3, time.Second) {
+			t.Error("request for an expired segment still running after its round failed")
+		}
+
+		rest, err := remapSegments(segments, fresh, done)
+		return append(segments[:done:done], rest...), err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(refreshedAt) != 1 || refreshedAt[0] != 2 {
+		t.Errorf("refreshed at %v, want once after 2 segments", refreshedAt)
+	}
+	var want []byte
+	for i := range n {
+		want = append(want, tsSegment(i)...)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Error("written bytes differ from the segments in playlist order")
+	}
+	if last.Segment != n || last.Segments != n || last.Position != n*segmentDuration {
+		t.Errorf("progress = %+v, want all %d segments", last, n)
+	}
+}
+
+func TestDownloadPlaylistRefreshesOncePerPosition(t *testing.T) {
+	srv := newExpiringServer(t, 1, func(index int) bool { return index == 1 })
+	old := uniformSegments(srv.URL+"/old", 2, segmentDuration)
+	fresh := uniformSegments(srv.URL+"/new", 2, segmentDuration)
+
+	refreshes := 0
+	err := downloadPlaylist(context.Background(), newGreedyDownloader(srv.fetcher()), old, &bytes.Buffer{}, nil,
+		func(ctx context.Context, segments []playlistSegment, done int) ([]playlistSegment, error) {
+			refreshes++
+			return fresh, nil
+		})
+	if !isTokenExpired(err) {
+		t.Errorf("err = %v, want the expired token error", err)
+	}
+	if refreshes != 1 {
+		t.Errorf("refreshed %d times, want 1", refreshes)
+	}
+}
+
+func TestDownloadPlaylistReportsRefreshError(t *testing.T) {
+	srv := newExpiringServer(t, 0, nil)
+	refreshErr := errors.New("episode page gone")
+	err := downloadPlaylist(context.Background(), newGreedyDownloader(srv.fetcher()), uniformSegments(srv.URL+"/old", 1, segmentDuration), &bytes.Buffer{}, nil,
+		func(ctx context.Context, segments []playlistSegment, done int) ([]playlistSegment, error) {
+			return nil, refreshErr
+		})
+	if !errors.Is(err, refreshErr) || !isTokenExpired(err) {
+		t.Errorf("err = %v, want both the expired token and the refresh error", err)
+	}
+}
+
+func TestDownloadSegmentContext(t *testing.T) {
+	segment := tsSegment(7)
+	tests := []struct {
+		name     string
+		body     []byte
+		throttle func(index, attempt int) (bool, string)
+		attempts int
+		err      error
+	}{
+		{"unwraps the PNG envelope", pngEnvelope(segment), nil, 1, nil},
+		{"retries when rate limited", pngEnvelope(segment), func(i, attempt int) (bool, string) { return attempt < 2, "" }, 3, nil},
+		{"corrupt payload", pngEnvelope(segment[:len(segment)-10]), nil, maxRefetches + 1, mpegts.ErrAlignment},
+		{"not a PNG envelope", segment, nil, maxRefetches + 1, ErrLayoutChanged},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			srv := newSegmentServer(t, func(int) []byte { return tt.body }, tt.throttle)
+			ex, err := NewAniVietSubExtractor(srv.URL, WithHTTPClient(srv.Client()), WithoutWarmUp())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := ex.DownloadSegment(srv.URL + "/seg/0")
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("err = %v, want %v", err, tt.err)
+				}
+			} else if err != nil {
+				t.Errorf("err = %v", err)
+			} else if !bytes.Equal(data, segment) {
+				t.Errorf("payload = %q, want the segment without its envelope", data)
+			}
+			srv.mu.Lock()
+			defer srv.mu.Unlock()
+			if got := srv.attempts[0]; got != tt.attempts {
+				t.Errorf("%d requests, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestDownloadSegmentContextNeedsTokenForEncryptedURL(t *testing.T) {
+	srv := newSegmentServer(t, func(int) []byte { return pngEnvelope(tsSegment(0)) }, nil)
+	ex, err := NewAniVietSubExtractor(srv.URL, WithHTTPClient(srv.Client()), WithoutWarmUp())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ex.DownloadSegmentContext(context.Background(), srv.URL+"/hls/0123456789abcdef01234567.ts?e=abc", "")
+	if !errors.Is(err, ErrDecrypt) {
+		t.Errorf("err = %v, want ErrDecrypt", err)
+	}
+	if len(srv.attempts) != 0 {
+		t.Errorf("requested %v before decrypting the URL", srv.attempts)
+	}
+}
+
+func TestExtractTrendingMovies(t *testing.T) {
+	page := `<ul class="bxh-movie-phimletv">
+		<li>
+			<span class="number-rank">1</span>
+			<a class="thumb" href="/phim/one-piece-a1/"><img src="data:image/gif;base64,R0lGOD" data-src="https://cdn.example/one-piece.jpg"></a>
+			<h3 class="title-item"><a href="/phim/one-piece-a1/">
+				One Piece
+			</a></h3>
+			<span class="view">1,234,567 lượt xem</span>
+		</li>
+		<li>
+			<a class="thumb" href="/phim/frieren-a5123/"><img src="https://cdn.example/frieren.jpg"></a>
+			<h3 class="title-item"><a href="/phim/frieren-a5123/">Frieren</a></h3>
+		</li>
+		<li><h3 class="title-item"><a>No link</a></h3></li>
+	</ul>`
+
+	movies, err := extractTrendingMovies(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []RankedAnime{
+		{SimpleAnime: SimpleAnime{Id: 1, Title: "One Piece", Href: "/phim/one-piece-a1/", Thumbnail: "https://cdn.example/one-piece.jpg"}, Rank: 1, Views: 1234567},
+		// Without a rank badge the position in the list is used.
+		{SimpleAnime: SimpleAnime{Id: 5123, Title: "Frieren", Href: "/phim/frieren-a5123/", Thumbnail: "https://cdn.example/frieren.jpg"}, Rank: 2},
+	}
+	if len(movies) != len(want) {
+		t.Fatalf("movies = %+v, want %+v", movies, want)
+	}
+	for i := range want {
+		if movies[i] != want[i] {
+			t.Errorf("movie %d = %+v, want %+v", i, movies[i], want[i])
+		}
+	}
+}
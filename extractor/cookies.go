@@ -0,0 +1,337 @@
+package extractor
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// PersistentJar is an http.CookieJar that remembers persistent cookies in a
+// file, so Cloudflare clearance obtained in one run can be reused by the
+// next. Session cookies live only in memory, as they would in a browser.
+type PersistentJar struct {
+	mu      sync.Mutex
+	jar     *cookiejar.Jar
+	path    string
+	entries map[string]storedCookie
+	dirty   bool
+}
+
+// storedCookie is the on-disk form of a cookie together with the URL it was
+// received from, which is needed to replay it into a cookiejar.Jar.
+type storedCookie struct {
+	URL      string    `json:"url"`
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain"`
+	HostOnly bool      `json:"host_only,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Expires  time.Time `json:"expires"`
+	Secure   bool      `json:"secure,omitempty"`
+	HttpOnly bool      `json:"http_only,omitempty"`
+}
+
+func (c storedCookie) key() string {
+	return c.Domain + ";" + c.Path + ";" + c.Name
+}
+
+func (c storedCookie) cookie() *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Path:     c.Path,
+		Expires:  c.Expires,
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+	}
+	if !c.HostOnly {
+		cookie.Domain = c.Domain
+	}
+	return cookie
+}
+
+// DefaultCookieJarPath returns the file used by the CLI to persist cookies.
+func DefaultCookieJarPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "nem", "cookies.json"), nil
+}
+
+// NewPersistentJar opens the jar stored at path. A missing file yields an
+// empty jar; expired cookies are dropped while loading.
+func NewPersistentJar(path string) (*PersistentJar, error) {
+	jar, err := cookiejar.New(&cookiejar.Options{
+		PublicSuffixList: publicsuffix.List,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cookie jar: %w", err)
+	}
+
+	pj := &PersistentJar{
+		jar:     jar,
+		path:    path,
+		entries: make(map[string]storedCookie),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return pj, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read cookie jar: %w", err)
+	}
+
+	var stored []storedCookie
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("parse cookie jar %s: %w", path, err)
+	}
+
+	now := time.Now()
+	for _, c := range stored {
+		if !c.Expires.After(now) {
+			pj.dirty = true
+			continue
+		}
+		if err := pj.replay(c); err != nil {
+			continue
+		}
+		pj.entries[c.key()] = c
+	}
+
+	return pj, nil
+}
+
+func (pj *PersistentJar) replay(c storedCookie) error {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return err
+	}
+	pj.jar.SetCookies(u, []*http.Cookie{c.cookie()})
+	return nil
+}
+
+// SetCookies implements http.CookieJar.
+func (pj *PersistentJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	pj.mu.Lock()
+	defer pj.mu.Unlock()
+
+	pj.jar.SetCookies(u, cookies)
+
+	now := time.Now()
+	for _, c := range cookies {
+		sc := storedCookie{
+			URL:      (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}).String(),
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   strings.TrimPrefix(c.Domain, "."),
+			Path:     c.Path,
+			Expires:  c.Expires,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+		}
+		if sc.Domain == "" {
+			sc.Domain = u.Hostname()
+			sc.HostOnly = true
+		}
+		if sc.Path == "" {
+			sc.Path = "/"
+		}
+		if c.MaxAge > 0 {
+			sc.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		}
+
+		key := sc.key()
+		if c.MaxAge < 0 || (!sc.Expires.IsZero() && !sc.Expires.After(now)) {
+			if _, ok := pj.entries[key]; ok {
+				delete(pj.entries, key)
+				pj.dirty = true
+			}
+			continue
+		}
+		if sc.Expires.IsZero() || !pj.accepted(u, sc) {
+			continue
+		}
+
+		pj.entries[key] = sc
+		pj.dirty = true
+	}
+}
+
+// accepted reports whether the in-memory jar took c when it was set from u.
+// The jar drops cookies for foreign domains or public suffixes, and those
+// must not be saved and replayed either.
+func (pj *PersistentJar) accepted(u *url.URL, c storedCookie) bool {
+	scheme := u.Scheme
+	if c.Secure {
+		scheme = "https"
+	}
+	check := &url.URL{Scheme: scheme, Host: u.Host, Path: c.Path}
+	return slices.ContainsFunc(pj.jar.Cookies(check), func(cookie *http.Cookie) bool {
+		return cookie.Name == c.Name && cookie.Value == c.Value
+	})
+}
+
+// Cookies implements http.CookieJar.
+func (pj *PersistentJar) Cookies(u *url.URL) []*http.Cookie {
+	pj.mu.Lock()
+	jar := pj.jar
+	pj.mu.Unlock()
+
+	return jar.Cookies(u)
+}
+
+// Expiry returns when the persistent cookie called name that would be sent
+// to u expires. It reports false for session cookies and unknown names.
+func (pj *PersistentJar) Expiry(u *url.URL, name string) (time.Time, bool) {
+	pj.mu.Lock()
+	defer pj.mu.Unlock()
+
+	host := u.Hostname()
+	for _, c := range pj.entries {
+		if c.Name != name {
+			continue
+		}
+		if host == c.Domain || (!c.HostOnly && strings.HasSuffix(host, "."+c.Domain)) {
+			return c.Expires, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ImportNetscape loads cookies from a Netscape formatted cookies.txt file,
+// as exported by browser extensions, and returns how many were imported.
+// Session cookies (expiry 0) are only kept for the current run.
+func (pj *PersistentJar) ImportNetscape(r io.Reader) (int, error) {
+	count := 0
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		// Only the line break is trimmed: a cookie with an empty value
+		// ends in a tab that separates its last field.
+		line := strings.TrimRight(scanner.Text(), "\r\n")
+
+		httpOnly := false
+		if rest, ok := strings.CutPrefix(line, "#HttpOnly_"); ok {
+			line = rest
+			httpOnly = true
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return count, fmt.Errorf("cookies line %d: expected 7 tab separated fields, got %d", lineNo, len(fields))
+		}
+
+		domain, includeSubdomains, path, secure, expires, name, value := fields[0], fields[1], fields[2], fields[3], fields[4], fields[5], fields[6]
+		host := strings.TrimPrefix(domain, ".")
+
+		expiry, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil {
+			return count, fmt.Errorf("cookies line %d: invalid expiry %q", lineNo, expires)
+		}
+
+		scheme := "http"
+		if strings.EqualFold(secure, "TRUE") {
+			scheme = "https"
+		}
+
+		c := &http.Cookie{
+			Name:     name,
+			Value:    value,
+			Path:     path,
+			Secure:   strings.EqualFold(secure, "TRUE"),
+			HttpOnly: httpOnly,
+		}
+		if strings.EqualFold(includeSubdomains, "TRUE") {
+			c.Domain = host
+		}
+		if expiry > 0 {
+			c.Expires = time.Unix(expiry, 0)
+		}
+
+		pj.SetCookies(&url.URL{Scheme: scheme, Host: host, Path: "/"}, []*http.Cookie{c})
+		count++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return count, err
+	}
+	return count, nil
+}
+
+// Save writes the persistent cookies back to disk if they changed.
+func (pj *PersistentJar) Save() error {
+	pj.mu.Lock()
+	defer pj.mu.Unlock()
+
+	if !pj.dirty {
+		return nil
+	}
+
+	now := time.Now()
+	stored := make([]storedCookie, 0, len(pj.entries))
+	for _, c := range pj.entries {
+		if c.Expires.After(now) {
+			stored = append(stored, c)
+		}
+	}
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(pj.path), 0o700); err != nil {
+		return fmt.Errorf("create cookie jar directory: %w", err)
+	}
+
+	tmp := pj.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write cookie jar: %w", err)
+	}
+	if err := os.Rename(tmp, pj.path); err != nil {
+		return fmt.Errorf("write cookie jar: %w", err)
+	}
+
+	pj.dirty = false
+	return nil
+}
+
+// Clear forgets every cookie and removes the file backing the jar.
+func (pj *PersistentJar) Clear() error {
+	jar, err := cookiejar.New(&cookiejar.Options{
+		PublicSuffixList: publicsuffix.List,
+	})
+	if err != nil {
+		return err
+	}
+
+	pj.mu.Lock()
+	defer pj.mu.Unlock()
+
+	pj.jar = jar
+	pj.entries = make(map[string]storedCookie)
+	pj.dirty = false
+
+	if err := os.Remove(pj.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove cookie jar: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package extractor
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cookieValues returns the cookies the jar sends to rawURL by name.
+func cookieValues(t *testing.T, jar http.CookieJar, rawURL string) map[string]string {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make(map[string]string)
+	for _, c := range jar.Cookies(u) {
+		values[c.Name] = c.Value
+	}
+	return values
+}
+
+func TestImportNetscape(t *testing.T) {
+	exp := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	line := func(fields ...string) string {
+		return strings.Join(fields, "\t") + "\n"
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		count int
+		err   string
+		want  map[string]string
+	}{
+		{
+			name: "comments and blank lines",
+			input: "# Netscape HTTP Cookie File\n\n" +
+				line(".example.com", "TRUE", "/", "FALSE", exp, "cf_clearance", "abc") +
+				"   \n",
+			count: 1,
+			want:  map[string]string{"cf_clearance": "abc"},
+		},
+		{
+			name:  "HttpOnly prefix",
+			input: "#HttpOnly_" + line(".example.com", "TRUE", "/", "TRUE", exp, "session", "s3cr3t"),
+			count: 1,
+			want:  map[string]string{"session": "s3cr3t"},
+		},
+		{
+			name:  "empty value",
+			input: line("example.com", "FALSE", "/", "FALSE", exp, "consent", ""),
+			count: 1,
+			want:  map[string]string{"consent": ""},
+		},
+		{
+			name:  "CRLF line endings",
+			input: strings.ReplaceAll(line("example.com", "FALSE", "/", "FALSE", exp, "a", "1"), "\n", "\r\n"),
+			count: 1,
+			want:  map[string]string{"a": "1"},
+		},
+		{
+			name: "too few fields",
+			input: line("example.com", "FALSE", "/", "FALSE", exp, "a", "1") +
+				line("example.com", "FALSE", "/", "FALSE", exp, "b"),
+			count: 1,
+			err:   "cookies line 2: expected 7 tab separated fields, got 6",
+			want:  map[string]string{"a": "1"},
+		},
+		{
+			name:  "invalid expiry",
+			input: line("example.com", "FALSE", "/", "FALSE", "soon", "a", "1"),
+			err:   `cookies line 1: invalid expiry "soon"`,
+			want:  map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jar, err := NewPersistentJar(filepath.Join(t.TempDir(), "cookies.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			count, err := jar.ImportNetscape(strings.NewReader(tt.input))
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Errorf("err = %v, want %q", err, tt.err)
+				}
+			} else if err != nil {
+				t.Errorf("err = %v", err)
+			}
+			if count != tt.count {
+				t.Errorf("imported %d cookies, want %d", count, tt.count)
+			}
+
+			got := cookieValues(t, jar, "https://example.com/")
+			if len(got) != len(tt.want) {
+				t.Errorf("cookies = %v, want %v", got, tt.want)
+			}
+			for name, value := range tt.want {
+				if v, ok := got[name]; !ok || v != value {
+					t.Errorf("cookie %s = %q (present %v), want %q", name, v, ok, value)
+				}
+			}
+		})
+	}
+}
+
+func TestPersistentJarRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nem", "cookies.json")
+	jar, err := NewPersistentJar(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, _ := url.Parse("https://www.example.com/watch")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "cf_clearance", Value: "abc", Domain: ".example.com", Expires: time.Now().Add(time.Hour), HttpOnly: true},
+		{Name: "host", Value: "only", Path: "/", MaxAge: 3600},
+		{Name: "session", Value: "memory"},
+		{Name: "expired", Value: "gone", Expires: time.Now().Add(-time.Hour)},
+		// The jar rejects cookies for domains u does not belong to, so
+		// they must not be saved either.
+		{Name: "foreign", Value: "x", Domain: "other.org", Expires: time.Now().Add(time.Hour)},
+		{Name: "suffix", Value: "x", Domain: "com", Expires: time.Now().Add(time.Hour)},
+	})
+	if err := jar.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := NewPersistentJar(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cookieValues(t, loaded, "https://www.example.com/"); len(got) != 2 || got["cf_clearance"] != "abc" || got["host"] != "only" {
+		t.Errorf("cookies for www.example.com = %v, want cf_clearance and host", got)
+	}
+	if got := cookieValues(t, loaded, "https://cdn.example.com/"); len(got) != 1 || got["cf_clearance"] != "abc" {
+		t.Errorf("cookies for cdn.example.com = %v, want only the domain cookie", got)
+	}
+	if got := cookieValues(t, loaded, "https://other.org/"); len(got) != 0 {
+		t.Errorf("cookies for other.org = %v, want none", got)
+	}
+	if len(loaded.entries) != 2 {
+		t.Errorf("saved %d cookies, want 2: %v", len(loaded.entries), loaded.entries)
+	}
+
+	expires, ok := loaded.Expiry(u, "cf_clearance")
+	if !ok || time.Until(expires) < 59*time.Minute {
+		t.Errorf("Expiry(cf_clearance) = %v, %v, want about an hour from now", expires, ok)
+	}
+}
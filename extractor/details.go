@@ -0,0 +1,220 @@
+package extractor
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// infoField is a "Label: value" row of the movie page info boxes.
+type infoField struct {
+	// label is lowercased, without its trailing colon.
+	label string
+	li    *goquery.Selection
+}
+
+// infoList collects the rows of the movie page info boxes in page order.
+func infoList(doc *goquery.Document) []infoField {
+	var rows []infoField
+	doc.Find("ul.InfoList li").Each(func(i int, li *goquery.Selection) {
+		label := strings.TrimSpace(li.Find("strong").First().Text())
+		label = strings.ToLower(strings.TrimSuffix(label, ":"))
+		if label != "" {
+			rows = append(rows, infoField{label: label, li: li})
+		}
+	})
+	return rows
+}
+
+// infoText returns the value of the first row whose label contains one of
+// keys, with the label itself stripped.
+func infoText(rows []infoField, keys ...string) string {
+	li := infoRow(rows, keys...)
+	if li == nil {
+		return ""
+	}
+	label := li.Find("strong").First().Text()
+	value := strings.TrimPrefix(strings.TrimSpace(li.Text()), strings.TrimSpace(label))
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// infoLinks returns the link texts of the first row matching keys, which is
+// how the site lists genres and studios.
+func infoLinks(rows []infoField, keys ...string) []string {
+	li := infoRow(rows, keys...)
+	if li == nil {
+		return nil
+	}
+	var links []string
+	li.Find("a").Each(func(i int, a *goquery.Selection) {
+		if text := strings.TrimSpace(a.Text()); text != "" {
+			links = append(links, text)
+		}
+	})
+	return links
+}
+
+// infoRow returns the first row, in page order, whose label contains the
+// first of keys that any label contains.
+func infoRow(rows []infoField, keys ...string) *goquery.Selection {
+	for _, key := range keys {
+		for _, row := range rows {
+			if strings.Contains(row.label, key) {
+				return row.li
+			}
+		}
+	}
+	return nil
+}
+
+// parseStatus maps the site's status text ("Trọn bộ", "Đang chiếu",
+// "Tập 5/12", "Sắp chiếu", ...) to an AnimeStatus. Labels are matched as
+// whole words, so "Full" never matches inside a title like "Fullmetal".
+func parseStatus(text string) AnimeStatus {
+	lower := strings.ToLower(text)
+	has := func(phrases ...string) bool {
+		return slices.ContainsFunc(phrases, func(p string) bool { return containsWords(lower, p) })
+	}
+	switch {
+	case lower == "":
+		return StatusUnknown
+	case has("trọn bộ", "hoàn tất", "hoàn thành", "full", "end", "completed"):
+		return StatusCompleted
+	case has("sắp chiếu", "trailer", "upcoming"):
+		return StatusUpcoming
+	}
+
+	if m := episodeFractionRegex.FindStringSubmatch(lower); m != nil {
+		aired, _ := strconv.Atoi(m[1])
+		total, err := strconv.Atoi(m[2])
+		if err == nil && aired >= total {
+			return StatusCompleted
+		}
+	}
+	return StatusOngoing
+}
+
+// containsWords reports whether phrase occurs in text delimited by
+// anything but letters and digits. Unlike \b in regexp, this also works
+// next to Vietnamese letters.
+func containsWords(text, phrase string) bool {
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], phrase)
+		if i < 0 {
+			return false
+		}
+		start, end := offset+i, offset+i+len(phrase)
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !isWordRune(before) && !isWordRune(after) {
+			return true
+		}
+		offset = end
+	}
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+var (
+	// "Tập 5/12", "12/12", "5/??"
+	episodeFractionRegex = regexp.MustCompile(`(\d+)\s*/\s*(\d+|\?+)`)
+	// "12 Tập", "24 tập"
+	episodeCountRegex = regexp.MustCompile(`(?i)(\d+)\s*tập`)
+	yearRegex         = regexp.MustCompile(`\b(19|20)\d{2}\b`)
+)
+
+// parseEpisodeCount extracts the announced number of episodes from texts
+// like "Tập 5/12" or "12 Tập". It returns 0 when the total is unknown.
+func parseEpisodeCount(text string) int {
+	if m := episodeFractionRegex.FindStringSubmatch(text); m != nil {
+		total, _ := strconv.Atoi(m[2])
+		return total
+	}
+	if m := episodeCountRegex.FindStringSubmatch(text); m != nil {
+		total, _ := strconv.Atoi(m[1])
+		return total
+	}
+	return 0
+}
+
+// isDuration reports whether text describes a runtime, e.g. "24 phút/tập".
+func isDuration(text string) bool {
+	lower := strings.ToLower(text)
+	return strings.Contains(lower, "phút") || strings.Contains(lower, "min") ||
+		strings.Contains(lower, "giờ")
+}
+
+var seasonNames = map[string]string{
+	"xuân":   "spring",
+	"spring": "spring",
+	"hạ":     "summer",
+	"hè":     "summer",
+	"summer": "summer",
+	"thu":    "fall",
+	"fall":   "fall",
+	"autumn": "fall",
+	"đông":   "winter",
+	"winter": "winter",
+}
+
+// parseSeason splits texts like "Mùa Xuân - 2023" or "Spring 2023" into
+// a normalized season name and year, either of which may be missing.
+func parseSeason(text string) (string, int) {
+	var season string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return r == ' ' || r == '-' || r == ','
+	}) {
+		if name, ok := seasonNames[word]; ok {
+			season = name
+			break
+		}
+	}
+	return season, parseYear(text)
+}
+
+func parseYear(text string) int {
+	year, _ := strconv.Atoi(yearRegex.FindString(text))
+	return year
+}
+
+// splitTitles splits a list of alternative titles separated by commas or
+// slashes.
+func splitTitles(text string) []string {
+	var titles []string
+	for _, t := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == '/' }) {
+		if t = strings.TrimSpace(t); t != "" {
+			titles = append(titles, t)
+		}
+	}
+	return titles
+}
+
+// parseViews reads a view counter such as "1,234,567 lượt xem", ignoring
+// thousands separators.
+func parseViews(text string) int64 {
+	separators := strings.NewReplacer(",", "", ".", "")
+	for _, field := range strings.Fields(text) {
+		if v, err := strconv.ParseInt(separators.Replace(field), 10, 64); err == nil {
+			return v
+		}
+	}
+	return 0
+}
+
+// imageSource returns the image URL of img, preferring lazy-load attributes
+// over the placeholder in src.
+func imageSource(img *goquery.Selection) string {
+	for _, attr := range []string{"data-src", "data-lazy-src", "src"} {
+		if src := strings.TrimSpace(img.AttrOr(attr, "")); src != "" && !strings.HasPrefix(src, "data:") {
+			return src
+		}
+	}
+	return ""
+}
@@ -0,0 +1,79 @@
+package extractor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		text string
+		want AnimeStatus
+	}{
+		{"", StatusUnknown},
+		{"Trọn bộ", StatusCompleted},
+		{"Hoàn tất (12/12)", StatusCompleted},
+		{"Full", StatusCompleted},
+		{"Tập 12 END", StatusCompleted},
+		{"12_End", StatusCompleted},
+		{"12/12", StatusCompleted},
+		{"Đang chiếu", StatusOngoing},
+		{"Tập 5/12", StatusOngoing},
+		{"5/??", StatusOngoing},
+		{"Weekend", StatusOngoing},
+		{"Fullmetal", StatusOngoing},
+		{"Sắp chiếu", StatusUpcoming},
+		{"Trailer", StatusUpcoming},
+	}
+	for _, tt := range tests {
+		if got := parseStatus(tt.text); got != tt.want {
+			t.Errorf("parseStatus(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestInfoTextUsesPageOrder(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<ul class="InfoList">
+		<li><strong>Trạng thái:</strong> Đang chiếu</li>
+		<li><strong>Trạng thái phụ:</strong> Trọn bộ</li>
+		<li><strong>Thể loại:</strong> <a>Action</a>, <a>Drama</a></li>
+	</ul>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := infoList(doc)
+
+	// Both status rows match; the first one on the page must win on every
+	// run.
+	for range 20 {
+		if got := infoText(rows, "trạng thái"); got != "Đang chiếu" {
+			t.Fatalf("infoText = %q, want the first matching row", got)
+		}
+	}
+	if got := infoLinks(rows, "thể loại", "genre"); strings.Join(got, ",") != "Action,Drama" {
+		t.Errorf("infoLinks = %v", got)
+	}
+	if got := infoText(rows, "studio"); got != "" {
+		t.Errorf("infoText of a missing row = %q, want empty", got)
+	}
+}
+
+func TestParseViews(t *testing.T) {
+	tests := []struct {
+		text string
+		want int64
+	}{
+		{"1,234,567 lượt xem", 1234567},
+		{"Lượt xem: 98.765", 98765},
+		{"  42  ", 42},
+		{"lượt xem", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := parseViews(tt.text); got != tt.want {
+			t.Errorf("parseViews(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
@@ -1,216 +1,472 @@
 package extractor
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"math/rand/v2"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+// Strategy selects how segments of an episode are fetched.
+type Strategy string
+
+const (
+	// StrategyGreedy fetches segments one by one as fast as possible,
+	// backing off only when the server answers 429.
+	StrategyGreedy Strategy = "greedy"
+	// StrategyAdaptive fetches segments concurrently with a window that is
+	// tuned at runtime from rate limiting, latency and throughput signals.
+	StrategyAdaptive Strategy = "adaptive"
+	// StrategyParallel fetches segments with a fixed number of workers.
+	StrategyParallel Strategy = "parallel"
+)
+
+// Strategies lists every supported download strategy.
+var Strategies = []Strategy{StrategyGreedy, StrategyAdaptive, StrategyParallel}
+
+// ParseStrategy converts a user supplied name into a Strategy.
+func ParseStrategy(name string) (Strategy, error) {
+	for _, s := range Strategies {
+		if strings.EqualFold(name, string(s)) {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("unknown download strategy %q (available: greedy, adaptive, parallel)", name)
+}
+
+func newSegmentDownloader(strategy Strategy, fetcher *segmentFetcher) SegmentDownloader {
+	switch strategy {
+	case StrategyAdaptive:
+		return newAdaptiveDownloader(fetcher)
+	case StrategyParallel:
+		return newParallelDownloader(fetcher, defaultParallelWorkers)
+	default:
+		return newGreedyDownloader(fetcher)
+	}
+}
+
+// SegmentDownloader writes the segments of a playlist to w in order. Every
+// segment is validated and fetched again when corrupt, see fetchVerified.
+// The callback is invoked after each segment with its index and payload.
 type SegmentDownloader interface {
-	downloadSegments(urls []string, w io.Writer, callback func(float64)) error
+	downloadSegments(ctx context.Context, segments []playlistSegment, w io.Writer, callback func(index int, segment *fetchedSegment)) error
+}
+
+// segmentResponse is the outcome of a single segment request.
+type segmentResponse struct {
+	content    []byte
+	throttled  bool
+	retryAfter time.Duration
+}
+
+// segmentFetcher performs segment requests with the headers the CDN expects.
+type segmentFetcher struct {
+	client    *http.Client
+	referer   string
+	userAgent string
+}
+
+func (sf *segmentFetcher) fetch(ctx context.Context, url string) (*segmentResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Referer", sf.referer)
+	req.Header.Set("User-Agent", sf.userAgent)
+
+	resp, err := sf.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		io.Copy(io.Discard, resp.Body)
+		return &segmentResponse{
+			throttled:  true,
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError(resp)
+	}
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &segmentResponse{content: content}, nil
+}
+
+// parseRetryAfter understands the delta-seconds and HTTP-date forms of the
+// Retry-After header. It returns zero when the header is absent or invalid.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
+
+func sleepWithJitter(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	jitter := d/2 + time.Duration(rand.Float64()*float64(d/2))
+	return sleepContext(ctx, jitter)
+}
+
+// sleepContext pauses for d or until ctx is done, returning ctx.Err() in
+// the latter case.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 type greedyDownloader struct {
-	client  *http.Client
-	referer string
+	fetcher *segmentFetcher
 
 	backoff    time.Duration
 	maxBackoff time.Duration
 }
 
-func newGreedyDownloader(client *http.Client, referer string) *greedyDownloader {
+func newGreedyDownloader(fetcher *segmentFetcher) *greedyDownloader {
 	return &greedyDownloader{
-		client:     client,
-		referer:    referer,
+		fetcher:    fetcher,
 		backoff:    50 * time.Millisecond,
 		maxBackoff: 2 * time.Second,
 	}
 }
 
-func (gd *greedyDownloader) downloadSegments(urls []string, w io.Writer, callback func(float64)) error {
-	for i, url := range urls {
-		if err := gd.downloadSegment(url, w); err != nil {
-			return fmt.Errorf("failed to download segment %d/%d: %w", i+1, len(urls), err)
+func (gd *greedyDownloader) downloadSegments(ctx context.Context, segments []playlistSegment, w io.Writer, callback func(int, *fetchedSegment)) error {
+	for i, s := range segments {
+		segment, err := fetchVerified(ctx, s, gd.fetch)
+		if err != nil {
+			return fmt.Errorf("failed to download segment %d/%d: %w", i+1, len(segments), err)
+		}
+		if _, err := w.Write(segment.data); err != nil {
+			return fmt.Errorf("failed to write segments: %w", err)
 		}
 		if callback != nil {
-			callback(float64(i+1) / float64(len(urls)))
+			callback(i, segment)
 		}
 	}
 	return nil
 }
 
-func (gd *greedyDownloader) downloadSegment(url string, w io.Writer) error {
+func (gd *greedyDownloader) fetch(ctx context.Context, url string) ([]byte, error) {
+	return fetchSegmentWithBackoff(ctx, gd.fetcher, url, gd.backoff, gd.maxBackoff)
+}
+
+// fetchSegmentWithBackoff downloads a segment, retrying with exponential
+// backoff while the server keeps rate limiting.
+func fetchSegmentWithBackoff(ctx context.Context, fetcher *segmentFetcher, url string, backoff, maxBackoff time.Duration) ([]byte, error) {
 	const maxRetries = 10
-	currentBackoff := gd.backoff
+	currentBackoff := backoff
 	for range maxRetries {
-		content, shouldRetry, err := gd.fetchSegment(url)
-		if err != nil && !shouldRetry {
-			return err
+		resp, err := fetcher.fetch(ctx, url)
+		if err != nil {
+			return nil, err
 		}
-		if shouldRetry {
-			gd.sleepWithJitter(currentBackoff)
-			currentBackoff = min(currentBackoff*2, gd.maxBackoff)
+		if resp.throttled {
+			if err := sleepWithJitter(ctx, max(currentBackoff, resp.retryAfter)); err != nil {
+				return nil, err
+			}
+			currentBackoff = min(currentBackoff*2, maxBackoff)
 			continue
 		}
-		segments, err := extractDataAfterIEND(content)
-		if err != nil {
-			return fmt.Errorf("failed to extract segments: %w", err)
+		return resp.content, nil
+	}
+	return nil, fmt.Errorf("max retries exceeded for URL %s: %w", url, ErrRateLimited)
+}
+
+// segmentResult carries a fetched segment back to the ordered writer.
+type segmentResult struct {
+	index   int
+	segment *fetchedSegment
+	err     error
+}
+
+// downloadOrdered fetches segments concurrently and writes them to w in
+// playlist order. limit is consulted before every dispatch so callers can
+// change the concurrency while the download runs; lookahead bounds how many
+// finished segments may be buffered in memory waiting for a slower one.
+//
+// When ctx is done no new fetches are started and ctx.Err() is returned
+// right away; requests still in flight are aborted through the same ctx.
+func downloadOrdered(ctx context.Context, segments []playlistSegment, w io.Writer, callback func(int, *fetchedSegment), limit func() int, lookahead int, fetch func(ctx context.Context, url string) ([]byte, error)) error {
+	results := make(chan segmentResult, len(segments))
+	pending := make(map[int]*fetchedSegment)
+	next, written, inflight := 0, 0, 0
+
+	for written < len(segments) {
+		for next < len(segments) && inflight < max(limit(), 1) && next-written < lookahead {
+			go func(index int) {
+				segment, err := fetchVerified(ctx, segments[index], fetch)
+				results <- segmentResult{index: index, segment: segment, err: err}
+			}(next)
+			next++
+			inflight++
 		}
-		if _, err := w.Write(segments); err != nil {
-			return fmt.Errorf("failed to write segments: %w", err)
+
+		var r segmentResult
+		select {
+		case r = <-results:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		inflight--
+		if r.err != nil {
+			return fmt.Errorf("failed to download segment %d/%d: %w", r.index+1, len(segments), r.err)
+		}
+		pending[r.index] = r.segment
+
+		for {
+			segment, ok := pending[written]
+			if !ok {
+				break
+			}
+			delete(pending, written)
+			if _, err := w.Write(segment.data); err != nil {
+				return fmt.Errorf("failed to write segments: %w", err)
+			}
+			if callback != nil {
+				callback(written, segment)
+			}
+			written++
 		}
-		return nil
 	}
-	return fmt.Errorf("max retries exceeded for URL: %s", url)
+	return nil
 }
 
-func (gd *greedyDownloader) fetchSegment(url string) ([]byte, bool, error) {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return nil, false, err
-	}
-	req.Header.Set("Referer", gd.referer)
-	req.Header.Set("User-Agent", USER_AGENT)
+const defaultParallelWorkers = 4
 
-	resp, err := gd.client.Do(req)
-	if err != nil {
-		return nil, false, err
-	}
-	defer resp.Body.Close()
+type parallelDownloader struct {
+	fetcher *segmentFetcher
+	workers int
 
-	if resp.StatusCode == http.StatusTooManyRequests {
-		return nil, true, nil
-	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, false, fmt.Errorf("unexpected status: %d", resp.StatusCode)
-	}
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
 
-	content, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, false, err
+func newParallelDownloader(fetcher *segmentFetcher, workers int) *parallelDownloader {
+	return &parallelDownloader{
+		fetcher:    fetcher,
+		workers:    workers,
+		backoff:    100 * time.Millisecond,
+		maxBackoff: 4 * time.Second,
 	}
-	return content, false, nil
 }
 
-func (gd *greedyDownloader) sleepWithJitter(d time.Duration) {
-	jitter := d/2 + time.Duration(rand.Float64()*float64(d/2))
-	time.Sleep(jitter)
+func (pd *parallelDownloader) downloadSegments(ctx context.Context, segments []playlistSegment, w io.Writer, callback func(int, *fetchedSegment)) error {
+	limit := func() int { return pd.workers }
+	return downloadOrdered(ctx, segments, w, callback, limit, 2*pd.workers, func(ctx context.Context, url string) ([]byte, error) {
+		return fetchSegmentWithBackoff(ctx, pd.fetcher, url, pd.backoff, pd.maxBackoff)
+	})
 }
 
+// adaptiveDownloader fetches segments through an AIMD controlled window:
+// the number of concurrent requests grows additively while the server keeps
+// up and shrinks multiplicatively as soon as it pushes back.
 type adaptiveDownloader struct {
-	client        *http.Client
-	referer       string
-	delay         time.Duration
-	minDelay      time.Duration
-	maxDelay      time.Duration
-	successStreak int
+	fetcher *segmentFetcher
+	ctrl    *aimdController
 }
 
-func newAdaptiveDownloader(client *http.Client, referer string) *adaptiveDownloader {
+func newAdaptiveDownloader(fetcher *segmentFetcher) *adaptiveDownloader {
 	return &adaptiveDownloader{
-		client:   client,
-		referer:  referer,
-		delay:    0 * time.Millisecond,
-		minDelay: 0 * time.Millisecond,
-		maxDelay: 150 * time.Millisecond,
+		fetcher: fetcher,
+		ctrl:    newAIMDController(1, 8),
 	}
 }
 
-func (ad *adaptiveDownloader) downloadSegments(urls []string, w io.Writer, callback func(float64)) error {
-	for i, url := range urls {
-		if err := ad.downloadSegment(url, w); err != nil {
-			return fmt.Errorf("failed to download segment %d/%d: %w", i+1, len(urls), err)
-		}
-
-		if callback != nil {
-			callback(float64(i+1) / float64(len(urls)))
-		}
-
-		ad.updateDelay()
-		time.Sleep(ad.delay)
-	}
-	return nil
+func (ad *adaptiveDownloader) downloadSegments(ctx context.Context, segments []playlistSegment, w io.Writer, callback func(int, *fetchedSegment)) error {
+	return downloadOrdered(ctx, segments, w, callback, ad.ctrl.limit, 2*ad.ctrl.maxWindow, ad.downloadSegment)
 }
 
-func (ad *adaptiveDownloader) downloadSegment(url string, w io.Writer) error {
+func (ad *adaptiveDownloader) downloadSegment(ctx context.Context, url string) ([]byte, error) {
 	const maxRetries = 10
 
 	for range maxRetries {
-		content, shouldRetry, err := ad.fetchSegment(url)
-		if err != nil && !shouldRetry {
-			return err
-		}
-
-		if shouldRetry {
-			ad.applyBackoff()
-			continue
+		if err := ad.ctrl.wait(ctx); err != nil {
+			return nil, err
 		}
 
-		// Extract and write data
-		segments, err := extractDataAfterIEND(content)
+		start := time.Now()
+		resp, err := ad.fetcher.fetch(ctx, url)
 		if err != nil {
-			return fmt.Errorf("failed to extract segments: %w", err)
+			return nil, err
 		}
 
-		if _, err := w.Write(segments); err != nil {
-			return fmt.Errorf("failed to write segments: %w", err)
+		if resp.throttled {
+			ad.ctrl.onThrottle(resp.retryAfter)
+			continue
 		}
-
-		ad.successStreak++
-		return nil
+		ad.ctrl.onSuccess(time.Since(start), len(resp.content))
+		return resp.content, nil
 	}
 
-	return fmt.Errorf("max retries exceeded for URL: %s", url)
+	return nil, fmt.Errorf("max retries exceeded for URL %s: %w", url, ErrRateLimited)
 }
 
-func (ad *adaptiveDownloader) fetchSegment(url string) ([]byte, bool, error) {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return nil, false, err
+// aimdController keeps the concurrency window of the adaptive downloader.
+//
+//   - Every successful request grows the window by 1/window, so a full
+//     window of successes adds one slot (additive increase).
+//   - A 429 halves the window and starts a pause that every request waits
+//     for, doubling on consecutive throttles (multiplicative decrease). The
+//     window that triggered it becomes a ceiling for a while so the
+//     controller does not immediately walk back into the same limit.
+//   - A request much slower than the fastest seen so far is treated as a
+//     soft congestion signal and trims the window by a quarter.
+//   - When growing the window stops improving throughput, the window is
+//     capped the same way.
+type aimdController struct {
+	mu sync.Mutex
+
+	window    float64
+	minWindow int
+	maxWindow int
+	ceiling   float64
+	probeAt   time.Time
+
+	baseLatency time.Duration
+	latency     time.Duration
+
+	throughput     float64
+	lastThroughput float64
+	lastWindow     float64
+	bytes          int
+	sampleStart    time.Time
+
+	backoff     time.Duration
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	pausedUntil time.Time
+}
+
+const (
+	aimdProbeInterval  = 30 * time.Second
+	aimdLatencyFactor  = 3
+	aimdSampleInterval = 2 * time.Second
+	aimdEWMAWeight     = 0.3
+)
+
+func newAIMDController(minWindow, maxWindow int) *aimdController {
+	return &aimdController{
+		window:      float64(minWindow),
+		minWindow:   minWindow,
+		maxWindow:   maxWindow,
+		minBackoff:  100 * time.Millisecond,
+		maxBackoff:  10 * time.Second,
+		sampleStart: time.Now(),
 	}
+}
 
-	req.Header.Set("Referer", ad.referer)
-	req.Header.Set("User-Agent", USER_AGENT)
+func (c *aimdController) limit() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return int(c.window)
+}
 
-	resp, err := ad.client.Do(req)
-	if err != nil {
-		return nil, false, err
+// wait blocks while the controller is pausing after a throttle.
+func (c *aimdController) wait(ctx context.Context) error {
+	c.mu.Lock()
+	d := time.Until(c.pausedUntil)
+	c.mu.Unlock()
+	return sleepWithJitter(ctx, d)
+}
+
+func (c *aimdController) onThrottle(retryAfter time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Requests that were already in flight when the first 429 arrived
+	// belong to the same congestion event; only react to it once.
+	if time.Now().Before(c.pausedUntil) {
+		return
 	}
-	defer resp.Body.Close()
 
-	// Handle rate limiting
-	if resp.StatusCode == http.StatusTooManyRequests {
-		return nil, true, nil // Retry with backoff
+	c.holdAt(c.window - 1)
+	c.window = max(c.window/2, float64(c.minWindow))
+	if c.backoff == 0 {
+		c.backoff = c.minBackoff
+	} else {
+		c.backoff = min(c.backoff*2, c.maxBackoff)
 	}
+	pause := max(c.backoff, retryAfter)
+	if until := time.Now().Add(pause); until.After(c.pausedUntil) {
+		c.pausedUntil = until
+	}
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, false, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+func (c *aimdController) onSuccess(latency time.Duration, size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.backoff = 0
+	if c.baseLatency == 0 || latency < c.baseLatency {
+		c.baseLatency = latency
+	}
+	if c.latency == 0 {
+		c.latency = latency
+	} else {
+		c.latency = time.Duration(aimdEWMAWeight*float64(latency) + (1-aimdEWMAWeight)*float64(c.latency))
 	}
 
-	content, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, false, err
+	c.bytes += size
+	if elapsed := time.Since(c.sampleStart); elapsed >= aimdSampleInterval {
+		c.sampleThroughput(float64(c.bytes) / elapsed.Seconds())
+		c.bytes = 0
+		c.sampleStart = time.Now()
 	}
 
-	return content, false, nil
+	if c.latency > aimdLatencyFactor*c.baseLatency {
+		c.window = max(c.window*0.75, float64(c.minWindow))
+		return
+	}
+	limit := float64(c.maxWindow)
+	if time.Now().Before(c.probeAt) {
+		limit = c.ceiling
+	}
+	c.window = min(c.window+1/c.window, limit)
 }
 
-func (ad *adaptiveDownloader) applyBackoff() {
-	// Multiplicative backoff with jitter
-	ad.delay = min(time.Duration(float64(ad.delay)*1.8), ad.maxDelay)
-	jitter := ad.delay/2 + time.Duration(rand.Float64()*float64(ad.delay/2))
-	time.Sleep(jitter)
-	ad.successStreak = 0
+// holdAt caps the window at size until the next probe.
+func (c *aimdController) holdAt(size float64) {
+	c.ceiling = max(float64(int(size)), float64(c.minWindow))
+	c.probeAt = time.Now().Add(aimdProbeInterval)
 }
 
-func (ad *adaptiveDownloader) updateDelay() {
-	if ad.successStreak >= 5 {
-		ad.delay -= 10 * time.Millisecond
-		if ad.delay < ad.minDelay {
-			ad.delay = ad.minDelay
-		}
-		ad.successStreak = 0
+func (c *aimdController) sampleThroughput(sample float64) {
+	if c.throughput == 0 {
+		c.throughput = sample
+	} else {
+		c.throughput = aimdEWMAWeight*sample + (1-aimdEWMAWeight)*c.throughput
+	}
+
+	// A larger window that moves fewer bytes means we are past the point
+	// where extra concurrency helps; hold the window where it is.
+	if c.lastWindow > 0 && c.window > c.lastWindow && c.throughput < c.lastThroughput {
+		c.holdAt(c.lastWindow)
+		c.window = min(c.window, c.ceiling)
 	}
+	c.lastWindow = c.window
+	c.lastThroughput = c.throughput
 }
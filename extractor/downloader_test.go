@@ -0,0 +1,289 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// tsPacket builds a transport stream packet carrying payload, padded with
+// 0xFF stuffing bytes.
+func tsPacket(pid uint16, start bool, payload []byte) []byte {
+	p := bytes.Repeat([]byte{0xFF}, 188)
+	p[0] = 0x47
+	p[1] = byte(pid >> 8 & 0x1F)
+	if start {
+		p[1] |= 0x40
+	}
+	p[2] = byte(pid)
+	p[3] = 0x10 // payload only
+	copy(p[4:], payload)
+	return p
+}
+
+// tsSegment is a minimal valid segment: a PAT pointing to a PMT on PID
+// 0x1000, the PMT, and a packet on PID 0x100 holding marker.
+func tsSegment(marker int) []byte {
+	pat := []byte{0, 0x00, 0xB0, 13, 0, 1, 0xC1, 0, 0, 0, 1, 0xF0, 0x00, 0, 0, 0, 0}
+	pmt := []byte{0, 0x02, 0xB0, 18, 0, 1, 0xC1, 0, 0, 0xE1, 0x00, 0xF0, 0x00, 0x1B, 0xE1, 0x00, 0xF0, 0x00, 0, 0, 0, 0}
+	var b []byte
+	b = append(b, tsPacket(0, true, pat)...)
+	b = append(b, tsPacket(0x1000, true, pmt)...)
+	b = append(b, tsPacket(0x100, false, []byte(strconv.Itoa(marker)))...)
+	return b
+}
+
+// pngEnvelope wraps data the way the CDN serves segments: behind a PNG
+// image ending with an IEND chunk.
+func pngEnvelope(data []byte) []byte {
+	b := []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}
+	b = append(b, 0, 0, 0, 0, 'I', 'E', 'N', 'D', 0xAE, 0x42, 0x60, 0x82)
+	return append(b, data...)
+}
+
+// segmentServer serves segments at /seg/<index> and answers 429 whenever
+// throttle says so for a request.
+type segmentServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	attempts map[int]int
+	times    map[int][]time.Time
+}
+
+func newSegmentServer(t *testing.T, body func(index int) []byte, throttle func(index, attempt int) (bool, string)) *segmentServer {
+	s := &segmentServer{attempts: make(map[int]int), times: make(map[int][]time.Time)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		index, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/seg/"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		s.mu.Lock()
+		attempt := s.attempts[index]
+		s.attempts[index]++
+		s.times[index] = append(s.times[index], time.Now())
+		s.mu.Unlock()
+
+		if throttle != nil {
+			if ok, retryAfter := throttle(index, attempt); ok {
+				if retryAfter != "" {
+					w.Header().Set("Retry-After", retryAfter)
+				}
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+		}
+		// Later segments answer faster, so concurrent strategies receive
+		// them out of order.
+		time.Sleep(time.Duration(10-index%10) * time.Millisecond)
+		w.Write(body(index))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *segmentServer) segments(n int) []playlistSegment {
+	segments := make([]playlistSegment, n)
+	for i := range segments {
+		segments[i] = playlistSegment{URL: fmt.Sprintf("%s/seg/%d", s.URL, i)}
+	}
+	return segments
+}
+
+func (s *segmentServer) fetcher() *segmentFetcher {
+	return &segmentFetcher{client: s.Client(), referer: s.URL, userAgent: USER_AGENT}
+}
+
+func TestDownloadersWriteSegmentsInOrder(t *testing.T) {
+	const n = 12
+	var want []byte
+	for i := range n {
+		want = append(want, tsSegment(i)...)
+	}
+
+	for _, strategy := range Strategies {
+		t.Run(string(strategy), func(t *testing.T) {
+			srv := newSegmentServer(t,
+				func(i int) []byte { return pngEnvelope(tsSegment(i)) },
+				func(i, attempt int) (bool, string) { return i%3 == 1 && attempt == 0, "0" },
+			)
+
+			var buf bytes.Buffer
+			var indices []int
+			err := newSegmentDownloader(strategy, srv.fetcher()).downloadSegments(context.Background(), srv.segments(n), &buf, func(i int, _ *fetchedSegment) {
+				indices = append(indices, i)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("written bytes differ from the segments in playlist order")
+			}
+			for i, index := range indices {
+				if i != index {
+					t.Fatalf("callback indices = %v, want 0..%d in order", indices, n-1)
+				}
+			}
+			if len(indices) != n {
+				t.Errorf("callback called %d times, want %d", len(indices), n)
+			}
+			for i := 1; i < n; i += 3 {
+				if got := srv.attempts[i]; got != 2 {
+					t.Errorf("segment %d requested %d times, want 2", i, got)
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadersHonorRetryAfter(t *testing.T) {
+	for _, strategy := range Strategies {
+		t.Run(string(strategy), func(t *testing.T) {
+			srv := newSegmentServer(t,
+				func(i int) []byte { return pngEnvelope(tsSegment(i)) },
+				func(i, attempt int) (bool, string) { return attempt == 0, "1" },
+			)
+
+			var buf bytes.Buffer
+			err := newSegmentDownloader(strategy, srv.fetcher()).downloadSegments(context.Background(), srv.segments(1), &buf, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			times := srv.times[0]
+			if len(times) != 2 {
+				t.Fatalf("segment requested %d times, want 2", len(times))
+			}
+			// The pause is jittered between half and all of Retry-After.
+			if wait := times[1].Sub(times[0]); wait < 500*time.Millisecond {
+				t.Errorf("retried after %s, want at least half of Retry-After: 1", wait)
+			}
+		})
+	}
+}
+
+func TestDownloaderGivesUpWhenAlwaysThrottled(t *testing.T) {
+	srv := newSegmentServer(t,
+		func(i int) []byte { return pngEnvelope(tsSegment(i)) },
+		func(i, attempt int) (bool, string) { return true, "" },
+	)
+	gd := newGreedyDownloader(srv.fetcher())
+	gd.backoff, gd.maxBackoff = time.Millisecond, time.Millisecond
+
+	err := gd.downloadSegments(context.Background(), srv.segments(1), &bytes.Buffer{}, nil)
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("err = %v, want ErrRateLimited", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value    string
+		min, max time.Duration
+	}{
+		{"", 0, 0},
+		{"3", 3 * time.Second, 3 * time.Second},
+		{"0", 0, 0},
+		{"-5", 0, 0},
+		{"soon", 0, 0},
+		{time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat), 8 * time.Second, 10 * time.Second},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got < tt.min || got > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %s, want between %s and %s", tt.value, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestAIMDControllerHalvesOnThrottle(t *testing.T) {
+	c := newAIMDController(1, 8)
+	c.window = 8
+
+	c.onThrottle(0)
+	if got := c.limit(); got != 4 {
+		t.Fatalf("window after throttle = %d, want 4", got)
+	}
+	if !c.pausedUntil.After(time.Now()) {
+		t.Error("throttle did not start a pause")
+	}
+
+	// 429s of requests already in flight belong to the same event.
+	c.onThrottle(0)
+	if got := c.limit(); got != 4 {
+		t.Errorf("window after a second throttle in the same pause = %d, want 4", got)
+	}
+
+	c.pausedUntil = time.Time{}
+	c.onThrottle(0)
+	c.pausedUntil = time.Time{}
+	c.onThrottle(0)
+	c.pausedUntil = time.Time{}
+	c.onThrottle(0)
+	if got := c.limit(); got != 1 {
+		t.Errorf("window = %d, want it to stop at the minimum of 1", got)
+	}
+}
+
+func TestAIMDControllerPauseHonorsRetryAfter(t *testing.T) {
+	c := newAIMDController(1, 8)
+	c.onThrottle(5 * time.Second)
+	if pause := time.Until(c.pausedUntil); pause < 4*time.Second {
+		t.Errorf("pause = %s, want the 5s Retry-After", pause)
+	}
+}
+
+func TestAIMDControllerGrowsOnSuccess(t *testing.T) {
+	c := newAIMDController(1, 8)
+	last := c.window
+	for range 20 {
+		c.onSuccess(10*time.Millisecond, 1000)
+		if c.window <= last {
+			t.Fatalf("window did not grow: %v -> %v", last, c.window)
+		}
+		last = c.window
+	}
+	if got := c.limit(); got < 4 {
+		t.Errorf("window after 20 successes = %d, want at least 4", got)
+	}
+
+	for range 200 {
+		c.onSuccess(10*time.Millisecond, 1000)
+	}
+	if got := c.limit(); got != 8 {
+		t.Errorf("window = %d, want it to stop at the maximum of 8", got)
+	}
+}
+
+func TestAIMDControllerHoldsBelowThrottledWindow(t *testing.T) {
+	c := newAIMDController(1, 8)
+	c.window = 8
+	c.onThrottle(0)
+	for range 200 {
+		c.onSuccess(10*time.Millisecond, 1000)
+	}
+	if got := c.limit(); got != 7 {
+		t.Errorf("window = %d, want it held just below the throttled window of 8", got)
+	}
+}
+
+func TestAIMDControllerShrinksOnLatency(t *testing.T) {
+	c := newAIMDController(1, 8)
+	c.window = 6
+	c.onSuccess(10*time.Millisecond, 1000)
+	before := c.window
+	for range 5 {
+		c.onSuccess(200*time.Millisecond, 1000)
+	}
+	if c.window >= before {
+		t.Errorf("window = %v after slow responses, want below %v", c.window, before)
+	}
+}
@@ -0,0 +1,97 @@
+package extractor
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// EpisodeKind tells regular episodes apart from extras, which do not count
+// towards the episode numbering.
+type EpisodeKind string
+
+const (
+	EpisodeRegular EpisodeKind = "regular"
+	// EpisodeSpecial covers SP entries and recaps numbered in between
+	// regular episodes, such as 12.5.
+	EpisodeSpecial EpisodeKind = "special"
+	EpisodeOVA     EpisodeKind = "ova"
+)
+
+var (
+	// "12", "Tập 01", "12.5", "12_End"
+	episodeNumberRegex = regexp.MustCompile(`\d+(?:[.,]\d+)?`)
+	ovaRegex           = regexp.MustCompile(`(?i)\b(?:ova|oad)\d*\b`)
+	specialRegex       = regexp.MustCompile(`(?i)\b(?:sp|special|extra)\d*\b`)
+)
+
+// parseEpisodeTitle reads the episode number and kind from an episode
+// button label. numbered is false when the label has none, e.g. "SP" or
+// "Full".
+func parseEpisodeTitle(title string) (number float64, kind EpisodeKind, numbered bool) {
+	kind = EpisodeRegular
+	switch lower := strings.ToLower(title); {
+	case ovaRegex.MatchString(title):
+		kind = EpisodeOVA
+	case specialRegex.MatchString(title), strings.Contains(lower, "đặc biệt"):
+		kind = EpisodeSpecial
+	}
+
+	number, err := strconv.ParseFloat(strings.Replace(episodeNumberRegex.FindString(title), ",", ".", 1), 64)
+	if err != nil {
+		return 0, kind, false
+	}
+	if kind == EpisodeRegular && number != float64(int(number)) {
+		kind = EpisodeSpecial
+	}
+	return number, kind, true
+}
+
+// sortEpisodes orders episodes by number, placing extras after the regular
+// episode they share a number with and unnumbered ones last. Regular
+// episodes without a number, like a movie's "Full", are numbered after the
+// last numbered one.
+func sortEpisodes(episodes []Episode) {
+	slices.SortStableFunc(episodes, func(e1, e2 Episode) int {
+		if e1.Numbered != e2.Numbered {
+			if e1.Numbered {
+				return -1
+			}
+			return 1
+		}
+		if c := cmp.Compare(e1.Number, e2.Number); c != 0 {
+			return c
+		}
+		if (e1.Kind == EpisodeRegular) != (e2.Kind == EpisodeRegular) {
+			if e1.Kind == EpisodeRegular {
+				return -1
+			}
+			return 1
+		}
+		return compareEpisodeIDs(e1.Id, e2.Id)
+	})
+
+	var last float64
+	for i := range episodes {
+		if episodes[i].Kind != EpisodeRegular {
+			continue
+		}
+		if !episodes[i].Numbered {
+			episodes[i].Number = float64(int(last) + 1)
+			episodes[i].Numbered = true
+		}
+		last = episodes[i].Number
+	}
+}
+
+// compareEpisodeIDs orders numeric IDs by value and anything else as text.
+func compareEpisodeIDs(id1, id2 string) int {
+	n1, err1 := strconv.ParseInt(id1, 10, 64)
+	n2, err2 := strconv.ParseInt(id2, 10, 64)
+	if err1 != nil || err2 != nil {
+		return strings.Compare(id1, id2)
+	}
+	return cmp.Compare(n1, n2)
+}
@@ -0,0 +1,122 @@
+package extractor
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseEpisodeTitle(t *testing.T) {
+	tests := []struct {
+		title    string
+		number   float64
+		numbered bool
+		kind     EpisodeKind
+	}{
+		{"12", 12, true, EpisodeRegular},
+		{"Tập 01", 1, true, EpisodeRegular},
+		{"Tập 00", 0, true, EpisodeRegular},
+		{"1080", 1080, true, EpisodeRegular},
+		{"12.5", 12.5, true, EpisodeSpecial},
+		{"12,5", 12.5, true, EpisodeSpecial},
+		{"12_End", 12, true, EpisodeRegular},
+		{"Full", 0, false, EpisodeRegular},
+		{"Spy", 0, false, EpisodeRegular},
+		{"SP", 0, false, EpisodeSpecial},
+		{"sp2", 2, true, EpisodeSpecial},
+		{"Special 1", 1, true, EpisodeSpecial},
+		{"Extra", 0, false, EpisodeSpecial},
+		{"Đặc biệt", 0, false, EpisodeSpecial},
+		{"Tập đặc biệt 2", 2, true, EpisodeSpecial},
+		{"OVA", 0, false, EpisodeOVA},
+		{"OVA 2", 2, true, EpisodeOVA},
+		{"OAD1", 1, true, EpisodeOVA},
+		{"Nova", 0, false, EpisodeRegular},
+	}
+	for _, tt := range tests {
+		number, kind, numbered := parseEpisodeTitle(tt.title)
+		if number != tt.number || numbered != tt.numbered || kind != tt.kind {
+			t.Errorf("parseEpisodeTitle(%q) = %v, %s, %v, want %v, %s, %v", tt.title, number, kind, numbered, tt.number, tt.kind, tt.numbered)
+		}
+	}
+}
+
+// parsedEpisodes builds episodes from "id:title" pairs the way the details
+// page does.
+func parsedEpisodes(pairs ...string) []Episode {
+	episodes := make([]Episode, len(pairs))
+	for i, pair := range pairs {
+		id, title, _ := strings.Cut(pair, ":")
+		number, kind, numbered := parseEpisodeTitle(title)
+		episodes[i] = Episode{Id: id, Title: title, Number: number, Numbered: numbered, Kind: kind}
+	}
+	return episodes
+}
+
+func TestSortEpisodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		episodes []Episode
+		want     string // title=number in the sorted order
+	}{
+		{
+			name:     "numbers",
+			episodes: parsedEpisodes("3:03", "1:01", "2:02"),
+			want:     "01=1 02=2 03=3",
+		},
+		{
+			name:     "extras after the regular episode they share a number with",
+			episodes: parsedEpisodes("5:OVA 2", "4:SP", "3:02", "2:12,5", "1:01", "6:12.5", "7:12"),
+			want:     "01=1 02=2 OVA 2=2 12=12 12,5=12.5 12.5=12.5 SP=0",
+		},
+		{
+			name:     "unnumbered regular episodes after the last numbered one",
+			episodes: parsedEpisodes("9:Full", "2:02", "8:Đặc biệt", "1:01", "7:12_End"),
+			want:     "01=1 02=2 12_End=12 Full=13 Đặc biệt=0",
+		},
+		{
+			name:     "movie",
+			episodes: parsedEpisodes("105430:Full"),
+			want:     "Full=1",
+		},
+		{
+			name:     "several unnumbered regular episodes",
+			episodes: parsedEpisodes("20:Full", "3:Trailer", "1:SP"),
+			want:     "Trailer=1 Full=2 SP=0",
+		},
+		{
+			name:     "episode 0 stays first",
+			episodes: parsedEpisodes("3:Tập 01", "2:Tập 00", "4:SP", "1:Full"),
+			want:     "Tập 00=0 Tập 01=1 Full=2 SP=0",
+		},
+		{
+			name:     "renumbering continues after a recap",
+			episodes: parsedEpisodes("2:12.5", "1:12", "3:Full"),
+			want:     "12=12 12.5=12.5 Full=13",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sortEpisodes(tt.episodes)
+			var got []string
+			for _, e := range tt.episodes {
+				got = append(got, fmt.Sprintf("%s=%g", e.Title, e.Number))
+			}
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("sorted = %s, want %s", strings.Join(got, " "), tt.want)
+			}
+		})
+	}
+}
+
+func TestSortEpisodesByID(t *testing.T) {
+	episodes := parsedEpisodes("20:05", "100:05", "7:05", "a:05")
+	sortEpisodes(episodes)
+	var ids []string
+	for _, e := range episodes {
+		ids = append(ids, e.Id)
+	}
+	if got := strings.Join(ids, ","); got != "7,20,100,a" {
+		t.Errorf("IDs = %s, want numeric order with text last", got)
+	}
+}
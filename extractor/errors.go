@@ -0,0 +1,53 @@
+package extractor
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Sentinel errors describing why an extractor call failed. They are wrapped
+// into the returned errors, so test for them with errors.Is.
+var (
+	// ErrNotFound means the requested anime or episode does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrBlocked means Cloudflare kept rejecting the request.
+	ErrBlocked = errors.New("blocked by cloudflare")
+	// ErrRateLimited means the server kept answering 429 Too Many Requests.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrLayoutChanged means a page or payload no longer has the expected
+	// structure, usually because the site changed.
+	ErrLayoutChanged = errors.New("site layout changed")
+	// ErrDecrypt means the playlist or a segment URL could not be decrypted.
+	ErrDecrypt = errors.New("decryption failed")
+)
+
+// HTTPError reports an unexpected HTTP status. It matches ErrNotFound,
+// ErrBlocked and ErrRateLimited for the corresponding status codes.
+type HTTPError struct {
+	URL        string
+	StatusCode int
+}
+
+func newHTTPError(resp *http.Response) *HTTPError {
+	return &HTTPError{
+		URL:        resp.Request.URL.String(),
+		StatusCode: resp.StatusCode,
+	}
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("unexpected status %d %s for %s", e.StatusCode, http.StatusText(e.StatusCode), e.URL)
+}
+
+func (e *HTTPError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound || e.StatusCode == http.StatusGone
+	case ErrBlocked:
+		return e.StatusCode == http.StatusForbidden
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
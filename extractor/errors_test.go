@@ -0,0 +1,32 @@
+package extractor
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestHTTPErrorIs(t *testing.T) {
+	sentinels := []error{ErrNotFound, ErrBlocked, ErrRateLimited, ErrLayoutChanged, ErrDecrypt}
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusGone, ErrNotFound},
+		{http.StatusForbidden, ErrBlocked},
+		{http.StatusTooManyRequests, ErrRateLimited},
+		{http.StatusInternalServerError, nil},
+		{http.StatusBadGateway, nil},
+		{http.StatusUnauthorized, nil},
+	}
+	for _, tt := range tests {
+		err := fmt.Errorf("listing: %w", &HTTPError{URL: "https://animevietsub.example/", StatusCode: tt.status})
+		for _, sentinel := range sentinels {
+			if got := errors.Is(err, sentinel); got != (sentinel == tt.want) {
+				t.Errorf("errors.Is(%d, %v) = %v", tt.status, sentinel, got)
+			}
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
 )
 
 type Episode struct {
@@ -12,6 +13,13 @@ type Episode struct {
 	Title   string
 	Href    string
 	Hash    string
+	// Number is the episode number read from the title; 12.5 for a recap
+	// between 12 and 13, 0 for extras without one.
+	Number float64
+	// Numbered is false for extras without a number, telling them apart
+	// from an episode 0.
+	Numbered bool
+	Kind     EpisodeKind
 }
 
 type SimpleAnime struct {
@@ -19,18 +27,79 @@ type SimpleAnime struct {
 	Title     string `json:"title"`
 	Thumbnail string `json:"thumbnail"`
 	Href      string `json:"href"`
+	// Episode is the latest episode label shown on listing cards, e.g.
+	// "Tập 5". Empty for search suggestions.
+	Episode string `json:"episode,omitempty"`
 }
 
+// RankedAnime is an entry of a trending ranking.
+type RankedAnime struct {
+	SimpleAnime
+	Rank  int   `json:"rank"`
+	Views int64 `json:"views"`
+}
+
+// Period selects the time span of a trending ranking.
+type Period string
+
+const (
+	PeriodDay    Period = "day"
+	PeriodWeek   Period = "week"
+	PeriodMonth  Period = "month"
+	PeriodSeason Period = "season"
+	PeriodYear   Period = "year"
+)
+
+// Periods lists every supported ranking period.
+var Periods = []Period{PeriodDay, PeriodWeek, PeriodMonth, PeriodSeason, PeriodYear}
+
+// ParsePeriod converts a user supplied name into a Period.
+func ParsePeriod(name string) (Period, error) {
+	for _, p := range Periods {
+		if strings.EqualFold(name, string(p)) {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unknown period %q (available: day, week, month, season, year)", name)
+}
+
+// AnimeStatus tells whether an anime is still airing.
+type AnimeStatus string
+
+const (
+	StatusUnknown   AnimeStatus = ""
+	StatusUpcoming  AnimeStatus = "upcoming"
+	StatusOngoing   AnimeStatus = "ongoing"
+	StatusCompleted AnimeStatus = "completed"
+)
+
 type AnimeDetail struct {
-	Id            int       `json:"id"`
-	Title         string    `json:"title"`
-	Subtitle      string    `json:"subtitle"`
-	Description   string    `json:"description"`
-	Rating        float64   `json:"rating"`
-	Href          string    `json:"href"`
-	TotalEpisodes string    `json:"total_episodes"`
-	Views         string    `json:"views"`
-	Episodes      []Episode `json:"episodes"`
+	Id          int      `json:"id"`
+	Title       string   `json:"title"`
+	Subtitle    string   `json:"subtitle"`
+	AltTitles   []string `json:"alt_titles,omitempty"`
+	Description string   `json:"description"`
+	Rating      float64  `json:"rating"`
+	Href        string   `json:"href"`
+	Poster      string   `json:"poster,omitempty"`
+	Banner      string   `json:"banner,omitempty"`
+
+	Genres []string    `json:"genres,omitempty"`
+	Studio string      `json:"studio,omitempty"`
+	Year   int         `json:"year,omitempty"`
+	Season string      `json:"season,omitempty"` // spring, summer, fall or winter
+	Status AnimeStatus `json:"status,omitempty"`
+
+	// TotalEpisodes is the announced number of episodes, which can be
+	// larger than len(Episodes) while the anime is airing. Zero if unknown.
+	TotalEpisodes int `json:"total_episodes,omitempty"`
+	// Duration is the runtime of one episode as shown by the site, e.g.
+	// "24 phút/tập".
+	Duration string `json:"duration,omitempty"`
+
+	Views    string        `json:"views"`
+	Related  []SimpleAnime `json:"related,omitempty"` // other seasons and parts
+	Episodes []Episode     `json:"episodes"`
 }
 
 func (m *AnimeDetail) String() string {
@@ -42,16 +111,49 @@ func (m *AnimeDetail) String() string {
 	fmt.Fprintf(&sb, "Subtitle: %s\n", m.Subtitle)
 	fmt.Fprintf(&sb, "Description: %s\n", m.Description)
 	fmt.Fprintf(&sb, "Rating: %.1f\n", m.Rating)
-	fmt.Fprintf(&sb, "Episodes: %s\n", m.TotalEpisodes)
+	fmt.Fprintf(&sb, "Genres: %s\n", strings.Join(m.Genres, ", "))
+	fmt.Fprintf(&sb, "Status: %s\n", m.Status)
+	fmt.Fprintf(&sb, "Episodes: %d/%d\n", len(m.Episodes), m.TotalEpisodes)
 
 	return sb.String()
 }
 
+// Progress describes how far an episode download has come. It is passed to
+// the Download callback after every segment written.
+type Progress struct {
+	// Segment is the number of segments written so far.
+	Segment int
+	// Segments is the number of segments in the playlist.
+	Segments int
+	// Bytes is the number of bytes written so far.
+	Bytes int64
+	// Position is the playback time covered by the written segments.
+	Position time.Duration
+	// Duration is the playback time of the whole playlist.
+	Duration time.Duration
+	// Refetched is the number of segments that had to be fetched again
+	// because their payload was corrupt.
+	Refetched int
+	// Damaged lists the segments that were still corrupt after every
+	// re-fetch.
+	Damaged []SegmentError
+}
+
+// Fraction returns the completed share of the download, between 0 and 1.
+func (p Progress) Fraction() float64 {
+	if p.Segments == 0 {
+		return 0
+	}
+	return float64(p.Segment) / float64(p.Segments)
+}
+
 type Extractor interface {
 	Search(query string) ([]SimpleAnime, error)
 	GetAnimeDetails(id int) (*AnimeDetail, error)
 	GetM3UPlaylist(e Episode) ([]byte, error)
-	Download(e Episode, w io.Writer, callback func(progress float64)) error
+	Download(e Episode, w io.Writer, callback func(p Progress)) error
 	DownloadSegment(url string) ([]byte, error)
-	Trending() ([]SimpleAnime, error)
+	Trending(period Period) ([]RankedAnime, error)
 }
+
+var _ Extractor = (*AniVietSubExtractor)(nil)
@@ -0,0 +1,103 @@
+package extractor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ppvan/nem/mpegts"
+)
+
+const (
+	// maxRefetches is how often a segment whose payload fails validation is
+	// fetched again before it is accepted as received.
+	maxRefetches = 3
+	// refetchDelay is the pause before the first re-fetch; later ones wait
+	// proportionally longer.
+	refetchDelay = 500 * time.Millisecond
+	// minSegmentRate is the lowest plausible payload rate of a segment in
+	// bytes per second. Even audio only streams stay well above it, so a
+	// smaller payload means the CDN cut the response short.
+	minSegmentRate = 8 << 10
+)
+
+// SegmentError describes a segment whose payload still failed validation
+// after every re-fetch. It was written as received and may show up as a
+// glitch in playback.
+type SegmentError struct {
+	// Index is the position of the segment in the playlist, starting at 0.
+	Index int
+	Err   error
+}
+
+func (e SegmentError) Error() string {
+	return fmt.Sprintf("segment %d: %v", e.Index+1, e.Err)
+}
+
+func (e SegmentError) Unwrap() error {
+	return e.Err
+}
+
+// fetchedSegment is the unwrapped payload of a segment and how it passed
+// validation.
+type fetchedSegment struct {
+	data []byte
+	// refetches counts the extra requests needed for a valid payload.
+	refetches int
+	// damage is the last validation error when no valid payload arrived.
+	damage error
+}
+
+// fetchVerified fetches segment with fetch, unwraps it from its PNG
+// envelope and validates the MPEG-TS payload, fetching it again while it is
+// corrupt. A segment whose envelope never parses fails the download, since
+// that points to a site change rather than a bad response.
+func fetchVerified(ctx context.Context, segment playlistSegment, fetch func(ctx context.Context, url string) ([]byte, error)) (*fetchedSegment, error) {
+	var damaged *fetchedSegment
+	var envelopeErr error
+	for attempt := range maxRefetches + 1 {
+		if attempt > 0 {
+			if err := sleepWithJitter(ctx, time.Duration(attempt)*refetchDelay); err != nil {
+				return nil, err
+			}
+		}
+
+		content, err := fetch(ctx, segment.URL)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := extractDataAfterIEND(content)
+		if err != nil {
+			envelopeErr = fmt.Errorf("failed to extract segments: %w: %w", ErrLayoutChanged, err)
+			continue
+		}
+		if err := validateSegment(data, segment.Duration); err != nil {
+			damaged = &fetchedSegment{data: data, refetches: attempt, damage: err}
+			continue
+		}
+		return &fetchedSegment{data: data, refetches: attempt}, nil
+	}
+
+	if damaged != nil {
+		damaged.refetches = maxRefetches
+		return damaged, nil
+	}
+	return nil, envelopeErr
+}
+
+// validateSegment checks that data is a complete MPEG-TS segment of
+// plausible size for duration.
+func validateSegment(data []byte, duration time.Duration) error {
+	if len(data) == 0 {
+		return errors.New("empty payload after IEND")
+	}
+	if err := mpegts.Check(data); err != nil {
+		return err
+	}
+	if duration > 0 && float64(len(data)) < minSegmentRate*duration.Seconds() {
+		return fmt.Errorf("only %d bytes for %s of video", len(data), duration)
+	}
+	return nil
+}
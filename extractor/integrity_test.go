@@ -0,0 +1,186 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ppvan/nem/mpegts"
+)
+
+// paddedSegment is tsSegment grown to packets packets with null packets.
+func paddedSegment(marker, packets int) []byte {
+	b := tsSegment(marker)
+	for len(b) < packets*mpegts.PacketSize {
+		b = append(b, tsPacket(0x1FFF, false, nil)...)
+	}
+	return b
+}
+
+func TestValidateSegment(t *testing.T) {
+	segment := tsSegment(0)
+	tests := []struct {
+		name     string
+		data     []byte
+		duration time.Duration
+		err      error
+		message  string
+	}{
+		{"valid", segment, 0, nil, ""},
+		{"valid for its duration", paddedSegment(0, 44), time.Second, nil, ""},
+		{"empty", []byte{}, time.Second, nil, "empty payload after IEND"},
+		{"misaligned", segment[:len(segment)-10], 0, mpegts.ErrAlignment, ""},
+		{"misaligned start", segment[3:], 0, mpegts.ErrAlignment, ""},
+		{"no PAT", segment[mpegts.PacketSize:], 0, mpegts.ErrNoPAT, ""},
+		{"no PMT", append(append([]byte{}, segment[:mpegts.PacketSize]...), segment[2*mpegts.PacketSize:]...), 0, mpegts.ErrNoPMT, ""},
+		// 564 bytes cannot hold a second of video at minSegmentRate.
+		{"short for its duration", segment, time.Second, nil, "only 564 bytes for 1s of video"},
+		{"short for a long duration", paddedSegment(0, 44), 2 * time.Second, nil, "only 8272 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSegment(tt.data, tt.duration)
+			switch {
+			case tt.err != nil:
+				if !errors.Is(err, tt.err) {
+					t.Errorf("err = %v, want %v", err, tt.err)
+				}
+			case tt.message != "":
+				if err == nil || !strings.Contains(err.Error(), tt.message) {
+					t.Errorf("err = %v, want %q", err, tt.message)
+				}
+			case err != nil:
+				t.Errorf("err = %v, want a valid segment", err)
+			}
+		})
+	}
+}
+
+// scriptedFetch answers the nth request with responses[n], repeating the
+// last one once the script runs out.
+type scriptedFetch struct {
+	mu        sync.Mutex
+	responses [][]byte
+	calls     int
+}
+
+func (f *scriptedFetch) fetch(ctx context.Context, url string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	response := f.responses[min(f.calls, len(f.responses)-1)]
+	f.calls++
+	return response, nil
+}
+
+func TestFetchVerified(t *testing.T) {
+	valid := pngEnvelope(tsSegment(1))
+	misaligned := pngEnvelope(tsSegment(2)[:500])
+	noPAT := pngEnvelope(append(tsPacket(0, true, nil), tsPacket(0x100, false, nil)...))
+	notPNG := []byte("<html>blocked</html>")
+
+	tests := []struct {
+		name      string
+		responses [][]byte
+		calls     int
+		refetches int
+		damage    error
+		err       error
+	}{
+		{"valid", [][]byte{valid}, 1, 0, nil, nil},
+		{"valid after a bad payload", [][]byte{misaligned, valid}, 2, 1, nil, nil},
+		{"valid after an empty payload", [][]byte{pngEnvelope(nil), pngEnvelope(nil), valid}, 3, 2, nil, nil},
+		{"valid after a bad envelope", [][]byte{notPNG, valid}, 2, 1, nil, nil},
+		{"damaged after every refetch", [][]byte{misaligned, noPAT}, maxRefetches + 1, maxRefetches, mpegts.ErrNoPAT, nil},
+		{"never an envelope", [][]byte{notPNG}, maxRefetches + 1, 0, nil, ErrLayoutChanged},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Every refetch waits a little longer, up to 3s in all.
+			t.Parallel()
+			f := &scriptedFetch{responses: tt.responses}
+			segment, err := fetchVerified(context.Background(), playlistSegment{URL: "seg"}, f.fetch)
+			if f.calls != tt.calls {
+				t.Errorf("fetched %d times, want %d", f.calls, tt.calls)
+			}
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if segment.refetches != tt.refetches {
+				t.Errorf("refetches = %d, want %d", segment.refetches, tt.refetches)
+			}
+			if tt.damage == nil {
+				if segment.damage != nil || !bytes.Equal(segment.data, tsSegment(1)) {
+					t.Errorf("got damage %v, want the valid payload", segment.damage)
+				}
+				return
+			}
+			if !errors.Is(segment.damage, tt.damage) {
+				t.Errorf("damage = %v, want %v", segment.damage, tt.damage)
+			}
+		})
+	}
+}
+
+func TestFetchVerifiedStopsOnFetchError(t *testing.T) {
+	calls := 0
+	_, err := fetchVerified(context.Background(), playlistSegment{URL: "seg"}, func(ctx context.Context, url string) ([]byte, error) {
+		calls++
+		return nil, ErrRateLimited
+	})
+	if !errors.Is(err, ErrRateLimited) || calls != 1 {
+		t.Errorf("err = %v after %d calls, want ErrRateLimited after 1", err, calls)
+	}
+}
+
+func TestDownloadPlaylistReportsDamagedSegments(t *testing.T) {
+	const n = 4
+	damaged := append(tsPacket(0, true, nil), tsPacket(0x100, false, nil)...)
+	srv := newSegmentServer(t, func(i int) []byte {
+		if i == 2 {
+			return pngEnvelope(damaged)
+		}
+		return pngEnvelope(tsSegment(i))
+	}, nil)
+
+	var buf bytes.Buffer
+	var last Progress
+	err := downloadPlaylist(context.Background(), newGreedyDownloader(srv.fetcher()), srv.segments(n), &buf, func(p Progress) {
+		last = p
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := srv.attempts[2]; got != maxRefetches+1 {
+		t.Errorf("damaged segment fetched %d times, want %d", got, maxRefetches+1)
+	}
+	if last.Segment != n || last.Refetched != 1 {
+		t.Errorf("progress = %d segments, %d refetched, want %d and 1", last.Segment, last.Refetched, n)
+	}
+	if len(last.Damaged) != 1 || last.Damaged[0].Index != 2 || !errors.Is(last.Damaged[0], mpegts.ErrNoPAT) {
+		t.Fatalf("Damaged = %v, want segment 3 without a PAT", last.Damaged)
+	}
+
+	// The damaged segment is still written, as received.
+	var want []byte
+	for i := range n {
+		if i == 2 {
+			want = append(want, damaged...)
+			continue
+		}
+		want = append(want, tsSegment(i)...)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Error("written bytes differ from the segments as received")
+	}
+}
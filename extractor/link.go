@@ -0,0 +1,55 @@
+package extractor
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Link is what a site URL points to.
+type Link struct {
+	AnimeID int
+	// EpisodeID is set for episode page URLs.
+	EpisodeID string
+}
+
+var (
+	// "sousou-no-frieren-a5124", or "-5124" as GetAnimeDetails requests it
+	animeSlugRegex = regexp.MustCompile(`-a?(\d+)$`)
+	// "tap-01-105430.html"
+	episodePageRegex = regexp.MustCompile(`-(\d+)\.html$`)
+)
+
+// ParseLink reads the anime and episode IDs from an anime or episode page
+// URL. Any domain is accepted, as the site moves between them often.
+func ParseLink(rawURL string) (Link, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Link{}, fmt.Errorf("invalid URL %q: %w", rawURL, err)
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	i := slices.Index(parts, "phim")
+	if i < 0 || i+1 >= len(parts) {
+		return Link{}, fmt.Errorf("%q is not an anime or episode URL", rawURL)
+	}
+	m := animeSlugRegex.FindStringSubmatch(parts[i+1])
+	if m == nil {
+		return Link{}, fmt.Errorf("no anime ID in URL %q", rawURL)
+	}
+	id, err := strconv.Atoi(m[1])
+	if err != nil {
+		return Link{}, fmt.Errorf("invalid anime ID in URL %q: %w", rawURL, err)
+	}
+
+	link := Link{AnimeID: id}
+	if i+2 < len(parts) {
+		if m := episodePageRegex.FindStringSubmatch(parts[i+2]); m != nil {
+			link.EpisodeID = m[1]
+		}
+	}
+	return link, nil
+}
@@ -0,0 +1,50 @@
+package extractor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLink(t *testing.T) {
+	tests := []struct {
+		url  string
+		want Link
+		err  string
+	}{
+		{url: "https://animevietsub.lol/phim/sousou-no-frieren-a5124/", want: Link{AnimeID: 5124}},
+		{url: "https://animevietsub.lol/phim/sousou-no-frieren-a5124", want: Link{AnimeID: 5124}},
+		{url: "http://animevietsub.page/phim/sousou-no-frieren-5124/", want: Link{AnimeID: 5124}},
+		{url: "animevietsub.lol/phim/sousou-no-frieren-a5124/", want: Link{AnimeID: 5124}},
+		{url: "/phim/sousou-no-frieren-a5124/", want: Link{AnimeID: 5124}},
+		{url: "https://animevietsub.lol/phim/-5124/", want: Link{AnimeID: 5124}},
+		{url: "https://animevietsub.lol/phim/sousou-no-frieren-a5124/?ref=home#comments", want: Link{AnimeID: 5124}},
+		{url: "https://animevietsub.lol/phim/sousou-no-frieren-a5124/tap-01-105430.html", want: Link{AnimeID: 5124, EpisodeID: "105430"}},
+		{url: "animevietsub.lol/phim/sousou-no-frieren-a5124/tap-12-end-105441.html", want: Link{AnimeID: 5124, EpisodeID: "105441"}},
+		{url: "https://animevietsub.lol/phim/sousou-no-frieren-a5124/trailer.html", want: Link{AnimeID: 5124}},
+
+		{url: "https://animevietsub.lol/", err: "not an anime or episode URL"},
+		{url: "https://animevietsub.lol/phim/", err: "not an anime or episode URL"},
+		{url: "https://animevietsub.lol/the-loai/hanh-dong/", err: "not an anime or episode URL"},
+		{url: "https://animevietsub.lol/danh-sach/phim-moi/", err: "not an anime or episode URL"},
+		{url: "https://animevietsub.lol/phim/sousou-no-frieren/", err: "no anime ID"},
+		{url: "https://animevietsub.lol/phim/a-99999999999999999999/", err: "invalid anime ID"},
+		{url: "https://animevietsub.lol/phim/%zz", err: "invalid URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			got, err := ParseLink(tt.url)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseLink = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package extractor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const SEARCH_PAGE = "/tim-kiem"
+
+// SortOrder selects how listing pages are ordered.
+type SortOrder string
+
+const (
+	SortDefault SortOrder = ""
+	SortLatest  SortOrder = "latest"
+	SortViews   SortOrder = "views"
+	SortRating  SortOrder = "rating"
+	SortName    SortOrder = "name"
+)
+
+// SortOrders lists every supported sort order.
+var SortOrders = []SortOrder{SortLatest, SortViews, SortRating, SortName}
+
+// ParseSortOrder converts a user supplied name into a SortOrder.
+func ParseSortOrder(name string) (SortOrder, error) {
+	if name == "" {
+		return SortDefault, nil
+	}
+	for _, s := range SortOrders {
+		if strings.EqualFold(name, string(s)) {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("unknown sort order %q (available: latest, views, rating, name)", name)
+}
+
+// ParseSeason validates a season name (spring, summer, fall or winter).
+func ParseSeason(name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	if season, ok := seasonNames[strings.ToLower(name)]; ok {
+		return season, nil
+	}
+	return "", fmt.Errorf("unknown season %q (available: spring, summer, fall, winter)", name)
+}
+
+// ParseStatus converts a user supplied name into an AnimeStatus.
+func ParseStatus(name string) (AnimeStatus, error) {
+	switch s := AnimeStatus(strings.ToLower(name)); s {
+	case StatusUnknown, StatusUpcoming, StatusOngoing, StatusCompleted:
+		return s, nil
+	}
+	return StatusUnknown, fmt.Errorf("unknown status %q (available: upcoming, ongoing, completed)", name)
+}
+
+// SearchOptions narrows down AdvancedSearch. Zero fields are not filtered
+// on.
+type SearchOptions struct {
+	Query  string
+	Genre  string // genre slug as used in the site URLs, e.g. "hanh-dong"
+	Year   int
+	Season string // spring, summer, fall or winter
+	Status AnimeStatus
+	Sort   SortOrder
+	Page   int // 1-based, defaults to the first page
+}
+
+// ListPage is one page of a paginated listing.
+type ListPage struct {
+	Results    []SimpleAnime `json:"results"`
+	Page       int           `json:"page"`
+	TotalPages int           `json:"total_pages"`
+}
+
+// HasNext reports whether another page follows this one.
+func (p *ListPage) HasNext() bool {
+	return p.Page < p.TotalPages
+}
+
+// site values of the filter parameters
+var (
+	seasonParams = map[string]string{
+		"spring": "xuan",
+		"summer": "ha",
+		"fall":   "thu",
+		"winter": "dong",
+	}
+	statusParams = map[AnimeStatus]string{
+		StatusUpcoming:  "sap-chieu",
+		StatusOngoing:   "dang-chieu",
+		StatusCompleted: "tron-bo",
+	}
+	sortParams = map[SortOrder]string{
+		SortLatest: "latest",
+		SortViews:  "view",
+		SortRating: "rating",
+		SortName:   "name",
+	}
+)
+
+// AdvancedSearch searches the full listing pages instead of the short
+// suggestion list returned by Search. Results carry their thumbnails.
+func (ex *AniVietSubExtractor) AdvancedSearch(opts SearchOptions) (*ListPage, error) {
+	elem := []string{SEARCH_PAGE}
+	if query := strings.TrimSpace(opts.Query); query != "" {
+		elem = append(elem, strings.ReplaceAll(query, "/", " "))
+	}
+
+	params := url.Values{}
+	if opts.Genre != "" {
+		params.Set("genre", opts.Genre)
+	}
+	if opts.Year != 0 {
+		params.Set("year", strconv.Itoa(opts.Year))
+	}
+	if v, ok := seasonParams[opts.Season]; ok {
+		params.Set("season", v)
+	}
+	if v, ok := statusParams[opts.Status]; ok {
+		params.Set("status", v)
+	}
+	if v, ok := sortParams[opts.Sort]; ok {
+		params.Set("sort", v)
+	}
+
+	return ex.fetchListing(CacheSearch, listingURL(ex.domain, opts.Page, elem...), params)
+}
+
+// listingURL builds the address of page of the listing at elem. The site
+// paginates with a trailing "trang-N.html" path element.
+func listingURL(domain string, page int, elem ...string) string {
+	if page > 1 {
+		elem = append(elem, fmt.Sprintf("trang-%d.html", page))
+		return mustJoinPath(domain, elem...)
+	}
+	return mustJoinPath(domain, elem...) + "/"
+}
+
+func (ex *AniVietSubExtractor) fetchListing(kind CacheKind, api string, params url.Values) (*ListPage, error) {
+	if len(params) > 0 {
+		api += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, api, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := ex.fetchCached(kind, req)
+	if err != nil {
+		return nil, fmt.Errorf("listing: %w", err)
+	}
+
+	page, err := extractListing(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("parse error: %w: %w", ErrLayoutChanged, err)
+	}
+
+	return page, nil
+}
+
+func extractListing(r io.Reader) (*ListPage, error) {
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &ListPage{Results: extractListItems(doc.Selection), Page: 1}
+
+	// The navigation shows the current page as a span and links to the
+	// others; the largest number among them is the last page.
+	nav := doc.Find(".wp-pagenavi")
+	if current, err := strconv.Atoi(strings.TrimSpace(nav.Find(".current").First().Text())); err == nil {
+		page.Page = current
+	}
+	page.TotalPages = page.Page
+	nav.Find("a").Each(func(i int, a *goquery.Selection) {
+		if n := pageNumber(a.AttrOr("href", "")); n > page.TotalPages {
+			page.TotalPages = n
+		}
+	})
+
+	return page, nil
+}
+
+// extractListItems parses the movie cards below s.
+func extractListItems(s *goquery.Selection) []SimpleAnime {
+	items := []SimpleAnime{}
+	s.Find("ul.MovieList li.TPostMv").Each(func(i int, s *goquery.Selection) {
+		a := s.Find("a").First()
+		href := a.AttrOr("href", "")
+		title := strings.TrimSpace(s.Find(".Title").First().Text())
+		if href == "" || title == "" {
+			return
+		}
+		items = append(items, SimpleAnime{
+			Id:        extractLargestNumber(href),
+			Title:     title,
+			Href:      href,
+			Thumbnail: imageSource(s.Find(".Image img").First()),
+			Episode:   strings.Join(strings.Fields(s.Find(".mli-eps").First().Text()), " "),
+		})
+	})
+	return items
+}
+
+// pageNumber extracts N from listing links ending in "trang-N.html".
+func pageNumber(href string) int {
+	i := strings.LastIndex(href, "trang-")
+	if i < 0 {
+		return 0
+	}
+	rest := href[i+len("trang-"):]
+	end := strings.IndexFunc(rest, func(r rune) bool { return r < '0' || r > '9' })
+	if end >= 0 {
+		rest = rest[:end]
+	}
+	n, _ := strconv.Atoi(rest)
+	return n
+}
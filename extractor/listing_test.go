@@ -0,0 +1,146 @@
+package extractor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// listingPage is a search result page in the layout of the site, showing
+// page 2 of 7.
+const listingPage = `<html><body>
+<ul class="MovieList">
+	<li class="TPostMv">
+		<a href="https://animevietsub.example/phim/fate-zero-a123/">
+			<div class="Image"><img src="data:image/gif;base64,R0lGOD" data-src="https://cdn.example/fate-zero.jpg"></div>
+			<span class="mli-eps">TẬP <i>25</i></span>
+			<h2 class="Title">Fate/Zero</h2>
+		</a>
+	</li>
+	<li class="TPostMv">
+		<a href="https://animevietsub.example/phim/mushishi-a4567/">
+			<div class="Image"><img src="https://cdn.example/mushishi.jpg"></div>
+			<h2 class="Title">Mushishi</h2>
+		</a>
+	</li>
+	<li class="TPostMv"><a href=""><h2 class="Title">No link</h2></a></li>
+</ul>
+<div class="wp-pagenavi">
+	<a href="/tim-kiem/fate/">1</a>
+	<span class="current">2</span>
+	<a href="/tim-kiem/fate/trang-3.html">3</a>
+	<a class="last" href="/tim-kiem/fate/trang-7.html">Cuối</a>
+</div>
+</body></html>`
+
+func TestExtractListing(t *testing.T) {
+	page, err := extractListing(strings.NewReader(listingPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if page.Page != 2 || page.TotalPages != 7 || !page.HasNext() {
+		t.Errorf("page %d of %d, want 2 of 7", page.Page, page.TotalPages)
+	}
+	want := []SimpleAnime{
+		{Id: 123, Title: "Fate/Zero", Href: "https://animevietsub.example/phim/fate-zero-a123/", Thumbnail: "https://cdn.example/fate-zero.jpg", Episode: "TẬP 25"},
+		{Id: 4567, Title: "Mushishi", Href: "https://animevietsub.example/phim/mushishi-a4567/", Thumbnail: "https://cdn.example/mushishi.jpg"},
+	}
+	if len(page.Results) != len(want) {
+		t.Fatalf("results = %+v, want %+v", page.Results, want)
+	}
+	for i := range want {
+		if page.Results[i] != want[i] {
+			t.Errorf("result %d = %+v, want %+v", i, page.Results[i], want[i])
+		}
+	}
+}
+
+func TestExtractListingWithoutPagination(t *testing.T) {
+	page, err := extractListing(strings.NewReader(`<ul class="MovieList"></ul>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Page != 1 || page.TotalPages != 1 || page.HasNext() || len(page.Results) != 0 {
+		t.Errorf("page = %+v, want a single empty page", page)
+	}
+}
+
+func TestPageNumber(t *testing.T) {
+	tests := []struct {
+		href string
+		want int
+	}{
+		{"/tim-kiem/fate/trang-3.html", 3},
+		{"https://animevietsub.example/anime-moi/trang-12.html", 12},
+		{"/the-loai/hanh-dong/trang-2.html?sort=view", 2},
+		{"/tim-kiem/fate/", 0},
+		{"/tim-kiem/trang-nha/trang-.html", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := pageNumber(tt.href); got != tt.want {
+			t.Errorf("pageNumber(%q) = %d, want %d", tt.href, got, tt.want)
+		}
+	}
+}
+
+func TestAdvancedSearchURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   SearchOptions
+		path   string
+		params url.Values
+	}{
+		{
+			name: "query only",
+			opts: SearchOptions{Query: " fate zero "},
+			path: "/tim-kiem/fate zero/",
+		},
+		{
+			name: "every filter",
+			opts: SearchOptions{Query: "fate/zero", Genre: "hanh-dong", Year: 2011, Season: "fall", Status: StatusCompleted, Sort: SortViews, Page: 3},
+			path: "/tim-kiem/fate zero/trang-3.html",
+			params: url.Values{
+				"genre":  {"hanh-dong"},
+				"year":   {"2011"},
+				"season": {"thu"},
+				"status": {"tron-bo"},
+				"sort":   {"view"},
+			},
+		},
+		{
+			name:   "filters without a query",
+			opts:   SearchOptions{Status: StatusUpcoming, Sort: SortName, Page: 1},
+			path:   "/tim-kiem/",
+			params: url.Values{"status": {"sap-chieu"}, "sort": {"name"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *url.URL
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.URL
+				w.Write([]byte(listingPage))
+			}))
+			defer srv.Close()
+
+			ex, err := NewAniVietSubExtractor(srv.URL, WithHTTPClient(srv.Client()), WithoutWarmUp())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ex.AdvancedSearch(tt.opts); err != nil {
+				t.Fatal(err)
+			}
+
+			if got.Path != tt.path {
+				t.Errorf("path = %q, want %q", got.Path, tt.path)
+			}
+			if params := got.Query(); params.Encode() != tt.params.Encode() {
+				t.Errorf("query = %v, want %v", params, tt.params)
+			}
+		})
+	}
+}
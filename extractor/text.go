@@ -14,13 +14,13 @@ func extractPlaylistLink(htmlContent string) (*url.URL, error) {
 	playerLinkRe := regexp.MustCompile(`PLAYER_DATA.+("link":)"(https[^"]+)"`)
 	match := playerLinkRe.FindStringSubmatch(htmlContent)
 	if match == nil {
-		return nil, fmt.Errorf("no match found")
+		return nil, fmt.Errorf("%w: no player link in episode page", ErrLayoutChanged)
 	}
 	rawLink := strings.ReplaceAll(match[2], `\/`, `/`)
 
 	playerLink, err := url.Parse(rawLink)
 	if err != nil {
-		return nil, fmt.Errorf("invalid player url: %w", err)
+		return nil, fmt.Errorf("%w: invalid player url: %w", ErrLayoutChanged, err)
 	}
 
 	return playerLink, nil
@@ -38,12 +38,12 @@ func extractPlayerData(playerHTML string) (*PlayerData, error) {
 
 	idMatch := idRegex.FindStringSubmatch(playerHTML)
 	if idMatch == nil {
-		return nil, fmt.Errorf("failed to extract id from player page")
+		return nil, fmt.Errorf("%w: failed to extract id from player page", ErrLayoutChanged)
 	}
 
 	tokenMatch := tokenRegex.FindStringSubmatch(playerHTML)
 	if tokenMatch == nil {
-		return nil, fmt.Errorf("failed to extract avsToken from player page")
+		return nil, fmt.Errorf("%w: failed to extract avsToken from player page", ErrLayoutChanged)
 	}
 
 	return &PlayerData{
@@ -81,7 +81,7 @@ func extractDataAfterIEND(raw []byte) ([]byte, error) {
 	pngSignature := []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A}
 
 	// Verify PNG signature
-	if len(raw) < len(pngSignature) {
+	if len(raw) < len(pngSignature) || !bytes.Equal(raw[:len(pngSignature)], pngSignature) {
 		return nil, errors.New("not a valid PNG file (missing PNG signature)")
 	}
 
@@ -2,10 +2,13 @@ module github.com/ppvan/nem
 
 go 1.24.6
 
-require github.com/PuerkitoBio/goquery v1.10.3
+require (
+	github.com/PuerkitoBio/goquery v1.10.3
+	github.com/fatih/color v1.18.0
+	golang.org/x/term v0.31.0
+)
 
 require (
-	github.com/fatih/color v1.18.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	golang.org/x/sys v0.32.0 // indirect
@@ -14,5 +17,5 @@ require (
 require (
 	github.com/andybalholm/cascadia v1.3.3 // indirect
 	github.com/urfave/cli/v3 v3.6.2
-	golang.org/x/net v0.39.0 // indirect
+	golang.org/x/net v0.39.0
 )
@@ -0,0 +1,241 @@
+// Package tstest builds small MPEG-TS streams for tests: a PAT and PMT
+// followed by H.264 and AAC PES packets with synthetic payloads.
+package tstest
+
+import "bytes"
+
+// PIDs used by Stream.
+const (
+	PMTPID   = 0x1000
+	VideoPID = 0x100
+	AudioPID = 0x101
+)
+
+// Stream types announced in the PMT.
+const (
+	typeAAC  = 0x0F
+	typeH264 = 0x1B
+)
+
+// Packet builds one transport stream packet. A payload shorter than a full
+// packet is padded with an adaptation field, as muxers do, so it reaches
+// the demuxer unchanged.
+func Packet(pid uint16, start bool, payload []byte) []byte {
+	p := make([]byte, 4, 188)
+	p[0] = 0x47
+	p[1] = byte(pid >> 8 & 0x1F)
+	if start {
+		p[1] |= 0x40
+	}
+	p[2] = byte(pid)
+	p[3] = 0x10
+
+	if n := len(payload); n < 184 {
+		p[3] = 0x30
+		stuffing := 183 - n
+		p = append(p, byte(stuffing))
+		if stuffing > 0 {
+			p = append(p, 0x00)
+			p = append(p, bytes.Repeat([]byte{0xFF}, stuffing-1)...)
+		}
+	}
+	return append(p, payload...)
+}
+
+// Packetize splits a PES packet into transport stream packets on pid.
+func Packetize(pid uint16, pes []byte) []byte {
+	var b []byte
+	for start := true; len(pes) > 0; start = false {
+		n := min(len(pes), 184)
+		b = append(b, Packet(pid, start, pes[:n])...)
+		pes = pes[n:]
+	}
+	return b
+}
+
+// PAT lists a single program whose PMT is on PMTPID.
+func PAT() []byte {
+	return Packet(0, true, []byte{
+		0,              // pointer field
+		0x00, 0xB0, 13, // table ID, section length
+		0, 1, 0xC1, 0, 0, // transport stream ID, version, section numbers
+		0, 1, 0xE0 | PMTPID>>8, PMTPID & 0xFF, // program 1
+		0, 0, 0, 0, // CRC, not checked
+	})
+}
+
+// PMT announces an H.264 stream on VideoPID and an AAC stream on AudioPID.
+func PMT() []byte {
+	return Packet(PMTPID, true, []byte{
+		0,
+		0x02, 0xB0, 23,
+		0, 1, 0xC1, 0, 0,
+		0xE0 | VideoPID>>8, VideoPID & 0xFF, // PCR PID
+		0xF0, 0x00, // program info length
+		typeH264, 0xE0 | VideoPID>>8, VideoPID & 0xFF, 0xF0, 0x00,
+		typeAAC, 0xE0 | AudioPID>>8, AudioPID & 0xFF, 0xF0, 0x00,
+		0, 0, 0, 0,
+	})
+}
+
+// PES wraps data in a PES packet with a PTS in 90 kHz units.
+func PES(streamID byte, pts int64, data []byte) []byte {
+	b := []byte{0, 0, 1, streamID, 0, 0, 0x80, 0x80, 5}
+	if streamID != 0xE0 && len(data)+8 <= 0xFFFF {
+		length := len(data) + 8
+		b[4], b[5] = byte(length>>8), byte(length)
+	}
+	b = append(b,
+		0x21|byte(pts>>30&0x07)<<1,
+		byte(pts>>22),
+		byte(pts>>15)<<1|1,
+		byte(pts>>7),
+		byte(pts)<<1|1,
+	)
+	return append(b, data...)
+}
+
+// bitWriter writes the Exp-Golomb coded fields of a parameter set.
+type bitWriter struct {
+	b []byte
+	n int
+}
+
+func (w *bitWriter) bit(v uint) {
+	if w.n%8 == 0 {
+		w.b = append(w.b, 0)
+	}
+	w.b[len(w.b)-1] |= byte(v&1) << (7 - w.n%8)
+	w.n++
+}
+
+func (w *bitWriter) bits(v uint, n int) {
+	for i := n - 1; i >= 0; i-- {
+		w.bit(v >> i)
+	}
+}
+
+func (w *bitWriter) ue(v uint) {
+	v++
+	n := 0
+	for x := v; x > 1; x >>= 1 {
+		n++
+	}
+	w.bits(0, n)
+	w.bits(v, n+1)
+}
+
+// SPS builds a baseline profile sequence parameter set for a width x
+// height picture. Heights that are not a multiple of 16 are cropped.
+func SPS(width, height int) []byte {
+	w := &bitWriter{}
+	w.bits(0x67, 8) // NAL header
+	w.bits(66, 8)   // profile_idc
+	w.bits(0, 8)    // constraint flags
+	w.bits(30, 8)   // level_idc
+	w.ue(0)         // seq_parameter_set_id
+	w.ue(0)         // log2_max_frame_num_minus4
+	w.ue(0)         // pic_order_cnt_type
+	w.ue(0)         // log2_max_pic_order_cnt_lsb_minus4
+	w.ue(1)         // max_num_ref_frames
+	w.bit(0)        // gaps_in_frame_num_value_allowed_flag
+
+	mbWidth, mbHeight := (width+15)/16, (height+15)/16
+	w.ue(uint(mbWidth - 1))
+	w.ue(uint(mbHeight - 1))
+	w.bit(1) // frame_mbs_only_flag
+	w.bit(1) // direct_8x8_inference_flag
+	if cropX, cropY := mbWidth*16-width, mbHeight*16-height; cropX > 0 || cropY > 0 {
+		w.bit(1)
+		w.ue(0)
+		w.ue(uint(cropX / 2))
+		w.ue(0)
+		w.ue(uint(cropY / 2))
+	} else {
+		w.bit(0)
+	}
+	w.bit(0) // vui_parameters_present_flag
+	w.bit(1) // rbsp_stop_one_bit
+	return w.b
+}
+
+// PPS is a placeholder picture parameter set; the muxer only copies it.
+var PPS = []byte{0x68, 0xCE, 0x38, 0x80}
+
+// AccessUnit builds an Annex B access unit with an access unit delimiter.
+// Keyframes carry the SPS and PPS and an IDR slice; marker makes the slice
+// data of every frame distinct.
+func AccessUnit(sps []byte, keyframe bool, marker byte) []byte {
+	startCode := []byte{0, 0, 0, 1}
+	b := append(append([]byte{}, startCode...), 0x09, 0xF0)
+	slice := []byte{0x41, 0x9A, marker, 0x80}
+	if keyframe {
+		b = append(append(b, startCode...), sps...)
+		b = append(append(b, startCode...), PPS...)
+		slice = []byte{0x65, 0x88, marker, 0x80}
+	}
+	return append(append(b, startCode...), slice...)
+}
+
+// SampleRateIndex48k is the ADTS sampling frequency index of 48 kHz.
+const SampleRateIndex48k = 3
+
+// ADTS builds an AAC LC frame with an ADTS header around payload.
+func ADTS(rateIndex, channels int, payload []byte) []byte {
+	length := 7 + len(payload)
+	return append([]byte{
+		0xFF, 0xF1,
+		byte(1<<6 | rateIndex<<2 | channels>>2),
+		byte(channels&3<<6 | length>>11),
+		byte(length >> 3),
+		byte(length&7<<5 | 0x1F),
+		0xFC,
+	}, payload...)
+}
+
+// Options shape the stream built by Stream.
+type Options struct {
+	Width, Height int
+	// Frames is the number of video frames at 25 fps.
+	Frames int
+	// GOP is the keyframe interval in frames.
+	GOP int
+	// StartPTS is the PTS of the first frame.
+	StartPTS int64
+	// NoAudio leaves out the AAC stream.
+	NoAudio bool
+}
+
+// AudioFramesPerPES is how many AAC frames every audio PES packet holds.
+const AudioFramesPerPES = 4
+
+// Stream builds a transport stream of opts.Frames video frames and, unless
+// disabled, 48 kHz stereo AAC of the same length. Timestamps wrap around
+// 2^33 like real ones. It returns the stream and the number of AAC frames.
+func Stream(opts Options) ([]byte, int) {
+	const frameTicks = 90000 / 25
+	const audioTicks = 1024 * 90000 / 48000
+
+	b := append(PAT(), PMT()...)
+	sps := SPS(opts.Width, opts.Height)
+	end := int64(opts.Frames) * frameTicks
+
+	var audioFrames int
+	var audioPTS int64
+	for i := range opts.Frames {
+		pts := int64(i) * frameTicks
+		au := AccessUnit(sps, i%opts.GOP == 0, byte(i))
+		b = append(b, Packetize(VideoPID, PES(0xE0, (opts.StartPTS+pts)%(1<<33), au))...)
+
+		for !opts.NoAudio && audioPTS <= pts && audioPTS < end {
+			var data []byte
+			for range AudioFramesPerPES {
+				data = append(data, ADTS(SampleRateIndex48k, 2, []byte{byte(audioFrames), 0x21, 0x10})...)
+				audioFrames++
+			}
+			b = append(b, Packetize(AudioPID, PES(0xC0, (opts.StartPTS+audioPTS)%(1<<33), data))...)
+			audioPTS += AudioFramesPerPES * audioTicks
+		}
+	}
+	return b, audioFrames
+}
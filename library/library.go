@@ -0,0 +1,394 @@
+package library
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry records one downloaded episode.
+type Entry struct {
+	AnimeID      int           `json:"anime_id"`
+	AnimeTitle   string        `json:"anime_title"`
+	EpisodeID    string        `json:"episode_id"`
+	EpisodeHash  string        `json:"episode_hash,omitempty"`
+	EpisodeTitle string        `json:"episode_title"`
+	Path         string        `json:"path"`
+	Size         int64         `json:"size"`
+	Duration     time.Duration `json:"duration"`
+	SourceURL    string        `json:"source_url"`
+	DownloadedAt time.Time     `json:"downloaded_at"`
+}
+
+func (e Entry) key() string {
+	return fmt.Sprintf("%d/%s", e.AnimeID, e.EpisodeID)
+}
+
+// Status describes whether the file of an entry is still where it was
+// recorded.
+type Status int
+
+const (
+	// StatusPresent means the file exists with the recorded size.
+	StatusPresent Status = iota
+	// StatusMissing means no file exists at the recorded path.
+	StatusMissing
+	// StatusChanged means a file exists but its size differs, e.g. it was
+	// overwritten or truncated.
+	StatusChanged
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusPresent:
+		return "present"
+	case StatusMissing:
+		return "missing"
+	case StatusChanged:
+		return "changed"
+	}
+	return "unknown"
+}
+
+// Check reports the Status of the file of e.
+func (e Entry) Check() Status {
+	info, err := os.Stat(e.Path)
+	if err != nil {
+		return StatusMissing
+	}
+	if info.Size() != e.Size {
+		return StatusChanged
+	}
+	return StatusPresent
+}
+
+// Library is the index of downloaded episodes, stored as a JSON file.
+type Library struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]Entry
+}
+
+// DefaultPath returns the library location inside the user data directory
+// ($XDG_DATA_HOME, ~/.local/share on Linux).
+func DefaultPath() (string, error) {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" && runtime.GOOS != "windows" && runtime.GOOS != "darwin" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	if dir == "" {
+		var err error
+		if dir, err = os.UserConfigDir(); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(dir, "nem", "library.json"), nil
+}
+
+// Open loads the library at path. A missing file is an empty library.
+func Open(path string) (*Library, error) {
+	entries, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Library{path: path, entries: entries}, nil
+}
+
+func load(path string) (map[string]Entry, error) {
+	entries := make(map[string]Entry)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read library: %w", err)
+	}
+
+	var stored []Entry
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("parse library %s: %w", path, err)
+	}
+	for _, e := range stored {
+		entries[e.key()] = e
+	}
+	return entries, nil
+}
+
+// Add records e, replacing a previous download of the same episode, and
+// saves the library.
+func (l *Library) Add(e Entry) error {
+	if abs, err := filepath.Abs(e.Path); err == nil {
+		e.Path = abs
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.update(func() bool {
+		l.entries[e.key()] = e
+		return true
+	})
+}
+
+// Find returns the entry of an episode, if it was downloaded.
+func (l *Library) Find(animeID int, episodeID string) (Entry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[Entry{AnimeID: animeID, EpisodeID: episodeID}.key()]
+	return e, ok
+}
+
+// Entries returns every entry ordered by anime title and episode.
+func (l *Library) Entries() []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := make([]Entry, 0, len(l.entries))
+	for _, e := range l.entries {
+		entries = append(entries, e)
+	}
+	slices.SortFunc(entries, compareEntries)
+	return entries
+}
+
+// Anime returns the entries of one anime ordered by episode.
+func (l *Library) Anime(id int) []Entry {
+	var entries []Entry
+	for _, e := range l.Entries() {
+		if e.AnimeID == id {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+func compareEntries(a, b Entry) int {
+	if c := strings.Compare(a.AnimeTitle, b.AnimeTitle); c != 0 {
+		return c
+	}
+	if a.AnimeID != b.AnimeID {
+		return a.AnimeID - b.AnimeID
+	}
+	if len(a.EpisodeID) != len(b.EpisodeID) {
+		return len(a.EpisodeID) - len(b.EpisodeID)
+	}
+	return strings.Compare(a.EpisodeID, b.EpisodeID)
+}
+
+// ScanResult is the outcome of Scan.
+type ScanResult struct {
+	// Moved lists entries whose file was found at a new path; the library
+	// now points there.
+	Moved []Entry
+	// Missing lists entries whose file could not be found.
+	Missing []Entry
+	// Changed lists entries whose file exists with a different size.
+	Changed []Entry
+}
+
+// Scan checks the file of every entry. Files that disappeared are looked
+// up by name and size below dirs, and entries are updated to follow them.
+func (l *Library) Scan(dirs ...string) (*ScanResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	type move struct{ key, from, to string }
+	var moves []move
+
+	result := &ScanResult{}
+	lost := make(map[string][]string) // file name -> keys of missing entries
+	for key, e := range l.entries {
+		switch e.Check() {
+		case StatusMissing:
+			lost[filepath.Base(e.Path)] = append(lost[filepath.Base(e.Path)], key)
+		case StatusChanged:
+			result.Changed = append(result.Changed, e)
+		}
+	}
+
+	for _, dir := range dirs {
+		if len(lost) == 0 {
+			break
+		}
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			keys := lost[d.Name()]
+			if len(keys) == 0 {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			for i, key := range keys {
+				e := l.entries[key]
+				if e.Size != info.Size() {
+					continue
+				}
+				if abs, err := filepath.Abs(path); err == nil {
+					path = abs
+				}
+				moves = append(moves, move{key: key, from: e.Path, to: path})
+				e.Path = path
+				l.entries[key] = e
+				result.Moved = append(result.Moved, e)
+				lost[d.Name()] = slices.Delete(keys, i, i+1)
+				break
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("scan %s: %w", dir, err)
+		}
+	}
+
+	for _, keys := range lost {
+		for _, key := range keys {
+			result.Missing = append(result.Missing, l.entries[key])
+		}
+	}
+	slices.SortFunc(result.Missing, compareEntries)
+	slices.SortFunc(result.Moved, compareEntries)
+	slices.SortFunc(result.Changed, compareEntries)
+
+	if len(moves) > 0 {
+		// Another process may have changed the library during the walk;
+		// only follow files whose entry still points to the old path.
+		err := l.update(func() bool {
+			for _, m := range moves {
+				if e, ok := l.entries[m.key]; ok && e.Path == m.from {
+					e.Path = m.to
+					l.entries[m.key] = e
+				}
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Prune removes the entries whose file no longer exists and returns them.
+func (l *Library) Prune() ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var removed []Entry
+	err := l.update(func() bool {
+		for key, e := range l.entries {
+			if e.Check() == StatusMissing {
+				removed = append(removed, e)
+				delete(l.entries, key)
+			}
+		}
+		return len(removed) > 0
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(removed, compareEntries)
+	return removed, nil
+}
+
+const (
+	// lockTimeout bounds how long an update waits for another nem process
+	// to finish its own.
+	lockTimeout = 10 * time.Second
+	// staleLock is the age after which a lock file is assumed to be left
+	// behind by a process that crashed.
+	staleLock = time.Minute
+)
+
+// update reloads the entries from disk, applies change to them and saves
+// the result, all under the lock file, so that nem processes running at the
+// same time never drop each other's entries. change reports whether it
+// modified anything. The caller holds l.mu.
+func (l *Library) update(change func() bool) error {
+	unlock, err := l.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, err := load(l.path)
+	if err != nil {
+		return err
+	}
+	l.entries = entries
+	if !change() {
+		return nil
+	}
+	return l.save()
+}
+
+// lock creates the lock file next to the library, waiting while another
+// process holds it, and returns the function releasing it.
+func (l *Library) lock() (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o700); err != nil {
+		return nil, fmt.Errorf("create library directory: %w", err)
+	}
+
+	path := l.path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("lock library: %w", err)
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("lock library: %s is held by another process", path)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func (l *Library) save() error {
+	stored := make([]Entry, 0, len(l.entries))
+	for _, e := range l.entries {
+		stored = append(stored, e)
+	}
+	slices.SortFunc(stored, compareEntries)
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o700); err != nil {
+		return fmt.Errorf("create library directory: %w", err)
+	}
+
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write library: %w", err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return fmt.Errorf("write library: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package library
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Separate Library values on one file stand in for nem processes running
+// at the same time.
+func TestConcurrentLibrariesKeepEachOthersEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "library.json")
+
+	const processes, episodes = 4, 10
+	var wg sync.WaitGroup
+	for p := range processes {
+		lib, err := Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range episodes {
+				err := lib.Add(Entry{AnimeID: p + 1, EpisodeID: fmt.Sprint(e), Path: fmt.Sprintf("/anime/%d/%d.ts", p, e)})
+				if err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	lib, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(lib.Entries()); got != processes*episodes {
+		t.Errorf("library has %d entries, want %d", got, processes*episodes)
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Error("lock file left behind")
+	}
+}
+
+func TestPruneKeepsEntriesAddedElsewhere(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "library.json")
+	video := filepath.Join(dir, "1.ts")
+	os.WriteFile(video, []byte("ts"), 0o644)
+
+	first, _ := Open(path)
+	second, _ := Open(path)
+	if err := first.Add(Entry{AnimeID: 1, EpisodeID: "1", Path: video, Size: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Add(Entry{AnimeID: 1, EpisodeID: "2", Path: filepath.Join(dir, "gone.ts")}); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := second.Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0].EpisodeID != "2" {
+		t.Errorf("removed = %v, want only the missing episode 2", removed)
+	}
+	if _, ok := second.Find(1, "1"); !ok {
+		t.Error("entry added by another process was dropped")
+	}
+}
+
+func TestStaleLockIsTakenOver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "library.json")
+	os.WriteFile(path+".lock", nil, 0o600)
+	old := time.Now().Add(-2 * staleLock)
+	os.Chtimes(path+".lock", old, old)
+
+	lib, _ := Open(path)
+	if err := lib.Add(Entry{AnimeID: 1, EpisodeID: "1", Path: "/a.ts"}); err != nil {
+		t.Fatal(err)
+	}
+}
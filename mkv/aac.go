@@ -0,0 +1,83 @@
+package mkv
+
+// adtsSampleRates maps the sampling frequency index of an ADTS header to
+// its rate in Hz.
+var adtsSampleRates = []int{
+	96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050,
+	16000, 12000, 11025, 8000, 7350,
+}
+
+// samplesPerFrame is the number of samples in an AAC frame.
+const samplesPerFrame = 1024
+
+// adtsHeader is the part of an ADTS header describing the stream.
+type adtsHeader struct {
+	objectType  int
+	rateIndex   int
+	channels    int
+	headerSize  int
+	frameLength int
+}
+
+func (h adtsHeader) sampleRate() int {
+	return adtsSampleRates[h.rateIndex]
+}
+
+// audioSpecificConfig builds the codec private data of an A_AAC track.
+func (h adtsHeader) audioSpecificConfig() []byte {
+	return []byte{
+		byte(h.objectType<<3 | h.rateIndex>>1),
+		byte(h.rateIndex&1<<7 | h.channels<<3),
+	}
+}
+
+// parseADTS reads the ADTS header at the start of b.
+func parseADTS(b []byte) (adtsHeader, bool) {
+	if len(b) < 7 || b[0] != 0xFF || b[1]&0xF6 != 0xF0 {
+		return adtsHeader{}, false
+	}
+	h := adtsHeader{
+		objectType:  int(b[2]>>6) + 1,
+		rateIndex:   int(b[2] >> 2 & 0x0F),
+		channels:    int(b[2]&0x01)<<2 | int(b[3]>>6),
+		headerSize:  7,
+		frameLength: int(b[3]&0x03)<<11 | int(b[4])<<3 | int(b[5]>>5),
+	}
+	if b[1]&0x01 == 0 { // CRC present
+		h.headerSize = 9
+	}
+	if h.rateIndex >= len(adtsSampleRates) || h.frameLength < h.headerSize {
+		return adtsHeader{}, false
+	}
+	return h, true
+}
+
+// splitADTS splits b into raw AAC frames. A trailing incomplete frame is
+// returned as rest so it can be completed by the next PES packet.
+func splitADTS(b []byte) (frames [][]byte, header adtsHeader, rest []byte) {
+	for len(b) > 0 {
+		if len(b) < 7 {
+			return frames, header, b
+		}
+		h, ok := parseADTS(b)
+		if !ok {
+			// Resynchronise on the next syncword.
+			i := 1
+			for i < len(b) && b[i] != 0xFF {
+				i++
+			}
+			if i == len(b) {
+				return frames, header, nil
+			}
+			b = b[i:]
+			continue
+		}
+		if len(b) < h.frameLength {
+			return frames, header, b
+		}
+		frames = append(frames, b[h.headerSize:h.frameLength])
+		header = h
+		b = b[h.frameLength:]
+	}
+	return frames, header, nil
+}
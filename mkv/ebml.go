@@ -0,0 +1,176 @@
+package mkv
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand/v2"
+)
+
+// Matroska element IDs, including their length marker bits.
+const (
+	idEBML               = 0x1A45DFA3
+	idEBMLVersion        = 0x4286
+	idEBMLReadVersion    = 0x42F7
+	idEBMLMaxIDLength    = 0x42F2
+	idEBMLMaxSizeLength  = 0x42F3
+	idDocType            = 0x4282
+	idDocTypeVersion     = 0x4287
+	idDocTypeReadVersion = 0x4285
+	idVoid               = 0xEC
+
+	idSegment      = 0x18538067
+	idSeekHead     = 0x114D9B74
+	idSeek         = 0x4DBB
+	idSeekID       = 0x53AB
+	idSeekPosition = 0x53AC
+
+	idInfo           = 0x1549A966
+	idTimestampScale = 0x2AD7B1
+	idDuration       = 0x4489
+	idTitle          = 0x7BA9
+	idMuxingApp      = 0x4D80
+	idWritingApp     = 0x5741
+
+	idTracks            = 0x1654AE6B
+	idTrackEntry        = 0xAE
+	idTrackNumber       = 0xD7
+	idTrackUID          = 0x73C5
+	idTrackType         = 0x83
+	idFlagLacing        = 0x9C
+	idLanguage          = 0x22B59C
+	idName              = 0x536E
+	idCodecID           = 0x86
+	idCodecPrivate      = 0x63A2
+	idVideo             = 0xE0
+	idPixelWidth        = 0xB0
+	idPixelHeight       = 0xBA
+	idAudio             = 0xE1
+	idSamplingFrequency = 0xB5
+	idChannels          = 0x9F
+
+	idCluster       = 0x1F43B675
+	idTimestamp     = 0xE7
+	idSimpleBlock   = 0xA3
+	idBlockGroup    = 0xA0
+	idBlock         = 0xA1
+	idBlockDuration = 0x9B
+
+	idCues               = 0x1C53BB6B
+	idCuePoint           = 0xBB
+	idCueTime            = 0xB3
+	idCueTrackPositions  = 0xB7
+	idCueTrack           = 0xF7
+	idCueClusterPosition = 0xF1
+
+	idChapters         = 0x1043A770
+	idEditionEntry     = 0x45B9
+	idChapterAtom      = 0xB6
+	idChapterUID       = 0x73C4
+	idChapterTimeStart = 0x91
+	idChapterDisplay   = 0x80
+	idChapString       = 0x85
+	idChapLanguage     = 0x437C
+
+	idAttachments   = 0x1941A469
+	idAttachedFile  = 0x61A7
+	idFileName      = 0x466E
+	idFileMediaType = 0x4660
+	idFileData      = 0x465C
+	idFileUID       = 0x46AE
+
+	idTags            = 0x1254C367
+	idTag             = 0x7373
+	idTargets         = 0x63C0
+	idTargetTypeValue = 0x68CA
+	idTargetType      = 0x63CA
+	idSimpleTag       = 0x67C8
+	idTagName         = 0x45A3
+	idTagString       = 0x4487
+)
+
+// Track types.
+const (
+	trackVideo    = 1
+	trackAudio    = 2
+	trackSubtitle = 17
+)
+
+// unknownSize is the 8 byte size of an element whose length is patched in
+// once it is known.
+var unknownSize = []byte{0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+
+func appendID(b []byte, id uint32) []byte {
+	switch {
+	case id > 0xFFFFFF:
+		return append(b, byte(id>>24), byte(id>>16), byte(id>>8), byte(id))
+	case id > 0xFFFF:
+		return append(b, byte(id>>16), byte(id>>8), byte(id))
+	case id > 0xFF:
+		return append(b, byte(id>>8), byte(id))
+	}
+	return append(b, byte(id))
+}
+
+// appendSize appends n as an EBML variable size integer of minimal length.
+func appendSize(b []byte, n uint64) []byte {
+	length := 1
+	for length < 8 && n >= 1<<(7*length)-1 {
+		length++
+	}
+	return appendSizeN(b, n, length)
+}
+
+func appendSizeN(b []byte, n uint64, length int) []byte {
+	n |= 1 << (7 * length)
+	for i := length - 1; i >= 0; i-- {
+		b = append(b, byte(n>>(8*i)))
+	}
+	return b
+}
+
+func element(id uint32, data []byte) []byte {
+	b := appendID(make([]byte, 0, len(data)+12), id)
+	b = appendSize(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+func master(id uint32, children ...[]byte) []byte {
+	var data []byte
+	for _, c := range children {
+		data = append(data, c...)
+	}
+	return element(id, data)
+}
+
+func uintElement(id uint32, v uint64) []byte {
+	n := 1
+	for n < 8 && v>>(8*n) != 0 {
+		n++
+	}
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(v >> (8 * (n - 1 - i)))
+	}
+	return element(id, data)
+}
+
+func floatElement(id uint32, v float64) []byte {
+	return element(id, binary.BigEndian.AppendUint64(nil, math.Float64bits(v)))
+}
+
+func stringElement(id uint32, s string) []byte {
+	return element(id, []byte(s))
+}
+
+// void returns a Void element exactly n bytes long, n >= 2.
+func void(n int) []byte {
+	if n-2 < 0x7F {
+		return append(appendSizeN([]byte{idVoid}, uint64(n-2), 1), make([]byte, n-2)...)
+	}
+	return append(appendSizeN([]byte{idVoid}, uint64(n-9), 8), make([]byte, n-9)...)
+}
+
+// newUID returns a random nonzero UID for tracks, chapters and attachments.
+func newUID() uint64 {
+	return rand.Uint64() | 1
+}
@@ -0,0 +1,231 @@
+package mkv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// NAL unit types used by the muxer.
+const (
+	nalIDR = 5
+	nalSPS = 7
+	nalPPS = 8
+	nalAUD = 9
+)
+
+// splitNALUnits splits an Annex B byte stream at its start codes.
+func splitNALUnits(b []byte) [][]byte {
+	var units [][]byte
+	start := -1
+	for i := 0; i+2 < len(b); i++ {
+		if b[i] != 0 || b[i+1] != 0 || b[i+2] != 1 {
+			continue
+		}
+		if start >= 0 {
+			units = append(units, bytes.TrimRight(b[start:i], "\x00"))
+		}
+		start = i + 3
+		i += 2
+	}
+	if start >= 0 && start < len(b) {
+		units = append(units, b[start:])
+	}
+	return units
+}
+
+// avcFrame is an access unit converted to the length prefixed form
+// Matroska stores.
+type avcFrame struct {
+	data     []byte
+	keyframe bool
+	sps, pps []byte
+}
+
+// parseAccessUnit converts an Annex B access unit, dropping access unit
+// delimiters, and picks out the parameter sets it carries.
+func parseAccessUnit(b []byte) avcFrame {
+	var f avcFrame
+	for _, nal := range splitNALUnits(b) {
+		if len(nal) == 0 {
+			continue
+		}
+		switch nal[0] & 0x1F {
+		case nalAUD:
+			continue
+		case nalIDR:
+			f.keyframe = true
+		case nalSPS:
+			f.sps = nal
+		case nalPPS:
+			f.pps = nal
+		}
+		f.data = binary.BigEndian.AppendUint32(f.data, uint32(len(nal)))
+		f.data = append(f.data, nal...)
+	}
+	return f
+}
+
+// avcConfig builds the AVCDecoderConfigurationRecord used as the codec
+// private data of a V_MPEG4/ISO/AVC track, with 4 byte NAL lengths.
+func avcConfig(sps, pps []byte) []byte {
+	b := []byte{1, sps[1], sps[2], sps[3], 0xFC | 3, 0xE0 | 1}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(sps)))
+	b = append(b, sps...)
+	b = append(b, 1)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(pps)))
+	return append(b, pps...)
+}
+
+// bitReader reads the Exp-Golomb coded fields of a parameter set.
+type bitReader struct {
+	b   []byte
+	pos int
+	err error
+}
+
+var errShortSPS = errors.New("truncated SPS")
+
+func (r *bitReader) bit() uint {
+	if r.pos >= len(r.b)*8 {
+		r.err = errShortSPS
+		return 0
+	}
+	v := r.b[r.pos/8] >> (7 - r.pos%8) & 1
+	r.pos++
+	return uint(v)
+}
+
+func (r *bitReader) bits(n int) uint {
+	var v uint
+	for range n {
+		v = v<<1 | r.bit()
+	}
+	return v
+}
+
+func (r *bitReader) ue() uint {
+	zeros := 0
+	for r.bit() == 0 && r.err == nil && zeros < 32 {
+		zeros++
+	}
+	return 1<<zeros - 1 + r.bits(zeros)
+}
+
+func (r *bitReader) se() int {
+	v := r.ue()
+	if v%2 == 1 {
+		return int(v+1) / 2
+	}
+	return -int(v / 2)
+}
+
+// unescapeRBSP removes the emulation prevention bytes of a NAL unit.
+func unescapeRBSP(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	zeros := 0
+	for _, c := range b {
+		if zeros >= 2 && c == 3 {
+			zeros = 0
+			continue
+		}
+		out = append(out, c)
+		if c == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	return out
+}
+
+// spsDimensions reads the cropped picture size from a sequence parameter
+// set.
+func spsDimensions(sps []byte) (width, height int, err error) {
+	r := &bitReader{b: unescapeRBSP(sps)}
+	r.bits(8) // NAL header
+	profile := r.bits(8)
+	r.bits(16) // constraint flags, level
+	r.ue()     // seq_parameter_set_id
+
+	chroma := uint(1)
+	switch profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		if chroma = r.ue(); chroma == 3 {
+			r.bit() // separate_colour_plane_flag
+		}
+		r.ue()  // bit_depth_luma_minus8
+		r.ue()  // bit_depth_chroma_minus8
+		r.bit() // qpprime_y_zero_transform_bypass_flag
+		if r.bit() == 1 {
+			lists := 8
+			if chroma == 3 {
+				lists = 12
+			}
+			for i := range lists {
+				if r.bit() == 0 {
+					continue
+				}
+				size := 16
+				if i >= 6 {
+					size = 64
+				}
+				last, next := 8, 8
+				for range size {
+					if next != 0 {
+						next = (last + r.se() + 256) % 256
+					}
+					if next != 0 {
+						last = next
+					}
+				}
+			}
+		}
+	}
+
+	r.ue() // log2_max_frame_num_minus4
+	switch r.ue() {
+	case 0:
+		r.ue() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		r.bit()
+		r.se()
+		r.se()
+		for range r.ue() {
+			r.se()
+		}
+	}
+	r.ue()  // max_num_ref_frames
+	r.bit() // gaps_in_frame_num_value_allowed_flag
+
+	widthMbs := r.ue() + 1
+	heightMapUnits := r.ue() + 1
+	frameMbsOnly := r.bit()
+	if frameMbsOnly == 0 {
+		r.bit() // mb_adaptive_frame_field_flag
+	}
+	r.bit() // direct_8x8_inference_flag
+
+	width = int(widthMbs) * 16
+	height = int((2-frameMbsOnly)*heightMapUnits) * 16
+	if r.bit() == 1 { // frame_cropping_flag
+		left, right, top, bottom := r.ue(), r.ue(), r.ue(), r.ue()
+		cropX, cropY := uint(1), 2-frameMbsOnly
+		switch chroma {
+		case 1:
+			cropX, cropY = 2, 2*(2-frameMbsOnly)
+		case 2:
+			cropX = 2
+		}
+		width -= int(cropX * (left + right))
+		height -= int(cropY * (top + bottom))
+	}
+
+	if r.err != nil {
+		return 0, 0, r.err
+	}
+	if width <= 0 || height <= 0 {
+		return 0, 0, errors.New("invalid SPS dimensions")
+	}
+	return width, height, nil
+}
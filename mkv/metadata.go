@@ -0,0 +1,110 @@
+package mkv
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Metadata describes an episode. It is stored as Matroska tags, the cover
+// as an attachment that players and file managers show as artwork.
+type Metadata struct {
+	// Title is the title of the series.
+	Title        string
+	EpisodeTitle string
+	// Episode is the episode number, 0 if unknown.
+	Episode     int
+	Description string
+	Genres      []string
+	Year        int
+	// Cover is a JPEG, PNG or WebP image.
+	Cover     []byte
+	Chapters  []Chapter
+	Subtitles []Subtitle
+}
+
+// title is the segment title shown by players: "Series - Episode".
+func (m *Metadata) title() string {
+	switch {
+	case m.Title != "" && m.EpisodeTitle != "":
+		return m.Title + " - " + m.EpisodeTitle
+	case m.Title != "":
+		return m.Title
+	}
+	return m.EpisodeTitle
+}
+
+// attachments stores the cover, named so that players and file managers
+// pick it up as artwork.
+func (m *Metadata) attachments() []byte {
+	if len(m.Cover) == 0 {
+		return nil
+	}
+	mediaType := http.DetectContentType(m.Cover)
+	name := "cover.jpg"
+	switch mediaType {
+	case "image/png":
+		name = "cover.png"
+	case "image/webp":
+		name = "cover.webp"
+	default:
+		mediaType = "image/jpeg"
+	}
+	return master(idAttachments, master(idAttachedFile,
+		stringElement(idFileName, name),
+		stringElement(idFileMediaType, mediaType),
+		uintElement(idFileUID, newUID()),
+		element(idFileData, m.Cover),
+	))
+}
+
+// tags stores the series at the COLLECTION level and the episode at the
+// EPISODE level, following the Matroska tagging guidelines.
+func (m *Metadata) tags() []byte {
+	series := []simpleTag{{"TITLE", m.Title}, {"DESCRIPTION", m.Description}}
+	for _, genre := range m.Genres {
+		series = append(series, simpleTag{"GENRE", genre})
+	}
+	if m.Year != 0 {
+		series = append(series, simpleTag{"DATE_RELEASED", strconv.Itoa(m.Year)})
+	}
+
+	episode := []simpleTag{{"TITLE", m.EpisodeTitle}}
+	if m.Episode != 0 {
+		episode = append(episode, simpleTag{"PART_NUMBER", strconv.Itoa(m.Episode)})
+	}
+
+	var tags [][]byte
+	if tag := tagElement(70, "COLLECTION", series); tag != nil {
+		tags = append(tags, tag)
+	}
+	if tag := tagElement(50, "EPISODE", episode); tag != nil {
+		tags = append(tags, tag)
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return master(idTags, tags...)
+}
+
+type simpleTag struct {
+	name, value string
+}
+
+func tagElement(targetType uint64, name string, values []simpleTag) []byte {
+	children := [][]byte{master(idTargets,
+		uintElement(idTargetTypeValue, targetType),
+		stringElement(idTargetType, name),
+	)}
+	for _, v := range values {
+		if v.value != "" {
+			children = append(children, master(idSimpleTag,
+				stringElement(idTagName, v.name),
+				stringElement(idTagString, v.value),
+			))
+		}
+	}
+	if len(children) == 1 {
+		return nil
+	}
+	return master(idTag, children...)
+}
@@ -0,0 +1,566 @@
+package mkv
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/ppvan/nem/mpegts"
+)
+
+// Chapter is a chapter marker.
+type Chapter struct {
+	Start time.Duration
+	Title string
+}
+
+// ErrNoStreams is returned by Remux when the input has no H.264 video or AAC
+// audio stream.
+var ErrNoStreams = errors.New("no H.264 or AAC stream")
+
+const (
+	// seekHeadSize is the space reserved for the SeekHead, which is written
+	// last once every top level element has been placed.
+	seekHeadSize = 160
+	// clusterLength is how long a cluster grows before the next keyframe
+	// starts a new one, in milliseconds.
+	clusterLength = 5000
+	// maxPending caps the frames buffered while waiting for the codec
+	// configuration of every track.
+	maxPending = 2000
+)
+
+type track struct {
+	number  int
+	kind    int
+	codecID string
+	private []byte
+	ready   bool
+
+	// video
+	width, height int
+	seenKeyframe  bool
+
+	// audio
+	sampleRate int
+	channels   int
+	rest       []byte
+	anchor     int64 // PTS of the last PES packet starting with a frame
+	count      int64 // frames since anchor
+
+	// subtitles
+	subtitle *Subtitle
+	cue      int
+}
+
+type frame struct {
+	track    *track
+	pts      int64
+	keyframe bool
+	data     []byte
+}
+
+type cuePoint struct {
+	time     int64
+	track    int
+	position int64
+}
+
+type seekEntry struct {
+	id       uint32
+	position int64
+}
+
+type muxer struct {
+	w    io.WriteSeeker
+	meta *Metadata
+	err  error
+
+	tracks    []*track
+	byPID     map[uint16]*track
+	subtitles []*track
+	hasVideo  bool
+	pending   []frame
+	started   bool
+
+	base    int64
+	lastPTS int64
+	wrap    int64
+
+	offset         int64
+	segmentSizePos int64
+	segmentStart   int64
+	seekHeadPos    int64
+	durationPos    int64
+	positions      []seekEntry
+	cluster        []byte
+	clusterStart   int64
+	clusterOpen    bool
+	cues           []cuePoint
+	end            int64
+}
+
+// Remux copies the H.264 and AAC streams of the MPEG-TS stream r into a
+// Matroska file written to w, together with the tags, cover, chapters and
+// subtitle tracks of meta, which may be nil. w must be positioned at its
+// start; sizes and the duration are patched in by seeking back.
+func Remux(w io.WriteSeeker, r io.Reader, meta *Metadata) error {
+	if meta == nil {
+		meta = &Metadata{}
+	}
+	m := &muxer{w: w, meta: meta, byPID: make(map[uint16]*track)}
+
+	demuxer := mpegts.NewDemuxer(r)
+	for {
+		pes, err := demuxer.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("demux: %w", err)
+		}
+
+		if len(m.byPID) == 0 {
+			m.addStreams(demuxer.Streams())
+		}
+		m.addPES(pes)
+
+		if !m.started && (m.ready() || len(m.pending) > maxPending) {
+			if err := m.start(); err != nil {
+				return err
+			}
+		}
+		if m.err != nil {
+			return m.err
+		}
+	}
+
+	if !m.started {
+		if err := m.start(); err != nil {
+			return err
+		}
+	}
+	return m.finish()
+}
+
+func (m *muxer) addStreams(streams []mpegts.Stream) {
+	for _, s := range streams {
+		switch s.Type {
+		case mpegts.StreamTypeH264:
+			m.byPID[s.PID] = &track{kind: trackVideo, codecID: "V_MPEG4/ISO/AVC"}
+		case mpegts.StreamTypeAAC:
+			m.byPID[s.PID] = &track{kind: trackAudio, codecID: "A_AAC"}
+		default:
+			continue
+		}
+		m.tracks = append(m.tracks, m.byPID[s.PID])
+	}
+}
+
+func (m *muxer) ready() bool {
+	for _, t := range m.tracks {
+		if !t.ready {
+			return false
+		}
+	}
+	return len(m.tracks) > 0
+}
+
+func (m *muxer) addPES(pes *mpegts.PES) {
+	t := m.byPID[pes.PID]
+	if t == nil {
+		return
+	}
+	pts := m.unwrap(pes.PTS)
+
+	switch t.kind {
+	case trackVideo:
+		f := parseAccessUnit(pes.Data)
+		if !t.ready && f.sps != nil && f.pps != nil {
+			if w, h, err := spsDimensions(f.sps); err == nil {
+				t.width, t.height = w, h
+				t.private = avcConfig(f.sps, f.pps)
+				t.ready = true
+			}
+		}
+		// Frames before the first keyframe cannot be decoded.
+		if f.keyframe {
+			t.seenKeyframe = true
+		}
+		if pts == mpegts.NoTimestamp || len(f.data) == 0 || !t.seenKeyframe {
+			return
+		}
+		m.emit(frame{track: t, pts: pts, keyframe: f.keyframe, data: f.data})
+
+	case trackAudio:
+		// Frames carry no timestamps of their own; count them from the
+		// PTS of the packet, which belongs to its first whole frame.
+		if len(t.rest) == 0 && pts != mpegts.NoTimestamp {
+			t.anchor, t.count = pts, 0
+		}
+		frames, header, rest := splitADTS(append(t.rest, pes.Data...))
+		t.rest = append([]byte(nil), rest...)
+		if len(frames) == 0 {
+			return
+		}
+		if !t.ready {
+			t.sampleRate = header.sampleRate()
+			t.channels = header.channels
+			t.private = header.audioSpecificConfig()
+			t.ready = true
+		}
+		for _, data := range frames {
+			pts := t.anchor + t.count*samplesPerFrame*90000/int64(t.sampleRate)
+			m.emit(frame{track: t, pts: pts, keyframe: true, data: data})
+			t.count++
+		}
+	}
+}
+
+// unwrap extends the 33 bit timestamps of the transport stream so they keep
+// increasing across the wrap around every 26.5 hours.
+func (m *muxer) unwrap(pts int64) int64 {
+	if pts == mpegts.NoTimestamp {
+		return pts
+	}
+	pts += m.wrap
+	switch {
+	case m.lastPTS != 0 && pts < m.lastPTS-1<<32:
+		m.wrap += 1 << 33
+		pts += 1 << 33
+	case m.lastPTS != 0 && pts > m.lastPTS+1<<32:
+		// A late packet from before the wrap.
+		return pts - 1<<33
+	}
+	m.lastPTS = pts
+	return pts
+}
+
+func (m *muxer) emit(f frame) {
+	if !m.started {
+		m.pending = append(m.pending, f)
+		return
+	}
+	m.writeFrame(f)
+}
+
+// start numbers the tracks whose configuration is known, writes the
+// header and the frames buffered so far.
+func (m *muxer) start() error {
+	m.started = true
+
+	for pid, t := range m.byPID {
+		if !t.ready {
+			delete(m.byPID, pid)
+		}
+	}
+
+	var tracks []*track
+	for _, t := range m.tracks {
+		if t.ready {
+			tracks = append(tracks, t)
+			t.number = len(tracks)
+			m.hasVideo = m.hasVideo || t.kind == trackVideo
+		}
+	}
+	if len(tracks) == 0 {
+		return ErrNoStreams
+	}
+	m.tracks = tracks
+
+	for i := range m.meta.Subtitles {
+		t := &track{
+			kind:     trackSubtitle,
+			codecID:  "S_TEXT/UTF8",
+			subtitle: &m.meta.Subtitles[i],
+			number:   len(m.tracks) + 1,
+		}
+		m.tracks = append(m.tracks, t)
+		m.subtitles = append(m.subtitles, t)
+	}
+
+	m.base = math.MaxInt64
+	for _, f := range m.pending {
+		if f.track.ready {
+			m.base = min(m.base, f.pts)
+		}
+	}
+	if m.base == math.MaxInt64 {
+		m.base = 0
+	}
+
+	m.writeHeader()
+	for _, f := range m.pending {
+		if f.track.ready {
+			m.writeFrame(f)
+		}
+	}
+	m.pending = nil
+	return m.err
+}
+
+func (m *muxer) write(b []byte) {
+	if m.err != nil {
+		return
+	}
+	n, err := m.w.Write(b)
+	m.offset += int64(n)
+	if err != nil {
+		m.err = fmt.Errorf("write: %w", err)
+	}
+}
+
+// writeTopLevel writes a child of the Segment and remembers its position
+// for the SeekHead.
+func (m *muxer) writeTopLevel(id uint32, b []byte) {
+	m.positions = append(m.positions, seekEntry{id: id, position: m.offset - m.segmentStart})
+	m.write(b)
+}
+
+func (m *muxer) writeHeader() {
+	m.write(master(idEBML,
+		uintElement(idEBMLVersion, 1),
+		uintElement(idEBMLReadVersion, 1),
+		uintElement(idEBMLMaxIDLength, 4),
+		uintElement(idEBMLMaxSizeLength, 8),
+		stringElement(idDocType, "matroska"),
+		uintElement(idDocTypeVersion, 4),
+		uintElement(idDocTypeReadVersion, 2),
+	))
+
+	m.write(appendID(nil, idSegment))
+	m.segmentSizePos = m.offset
+	m.write(unknownSize)
+	m.segmentStart = m.offset
+
+	m.seekHeadPos = m.offset
+	m.write(void(seekHeadSize))
+
+	info := master(idInfo,
+		uintElement(idTimestampScale, uint64(time.Millisecond)),
+		stringElement(idMuxingApp, "nem"),
+		stringElement(idWritingApp, "nem"),
+		stringElement(idTitle, m.meta.title()),
+		floatElement(idDuration, 0), // must stay last, patched in finish
+	)
+	m.durationPos = m.offset + int64(len(info)) - 8
+	m.writeTopLevel(idInfo, info)
+
+	var entries [][]byte
+	for _, t := range m.tracks {
+		entries = append(entries, t.entry())
+	}
+	m.writeTopLevel(idTracks, master(idTracks, entries...))
+
+	if chapters := m.chapters(); chapters != nil {
+		m.writeTopLevel(idChapters, chapters)
+	}
+	if attachments := m.meta.attachments(); attachments != nil {
+		m.writeTopLevel(idAttachments, attachments)
+	}
+	if tags := m.meta.tags(); tags != nil {
+		m.writeTopLevel(idTags, tags)
+	}
+}
+
+func (t *track) entry() []byte {
+	children := [][]byte{
+		uintElement(idTrackNumber, uint64(t.number)),
+		uintElement(idTrackUID, uint64(t.number)),
+		uintElement(idTrackType, uint64(t.kind)),
+		uintElement(idFlagLacing, 0),
+		stringElement(idCodecID, t.codecID),
+	}
+	if t.private != nil {
+		children = append(children, element(idCodecPrivate, t.private))
+	}
+
+	switch t.kind {
+	case trackVideo:
+		children = append(children,
+			stringElement(idLanguage, "und"),
+			master(idVideo,
+				uintElement(idPixelWidth, uint64(t.width)),
+				uintElement(idPixelHeight, uint64(t.height)),
+			))
+	case trackAudio:
+		children = append(children,
+			stringElement(idLanguage, "und"),
+			master(idAudio,
+				floatElement(idSamplingFrequency, float64(t.sampleRate)),
+				uintElement(idChannels, uint64(t.channels)),
+			))
+	case trackSubtitle:
+		language := t.subtitle.Language
+		if language == "" {
+			language = "und"
+		}
+		children = append(children, stringElement(idLanguage, language))
+		if t.subtitle.Name != "" {
+			children = append(children, stringElement(idName, t.subtitle.Name))
+		}
+	}
+	return master(idTrackEntry, children...)
+}
+
+func (m *muxer) chapters() []byte {
+	if len(m.meta.Chapters) == 0 {
+		return nil
+	}
+	var atoms [][]byte
+	for _, c := range m.meta.Chapters {
+		atoms = append(atoms, master(idChapterAtom,
+			uintElement(idChapterUID, newUID()),
+			uintElement(idChapterTimeStart, uint64(max(c.Start, 0))),
+			master(idChapterDisplay,
+				stringElement(idChapString, c.Title),
+				stringElement(idChapLanguage, "und"),
+			),
+		))
+	}
+	return master(idChapters, master(idEditionEntry, atoms...))
+}
+
+// ms converts a transport stream timestamp to milliseconds from the start
+// of the file.
+func (m *muxer) ms(pts int64) int64 {
+	return max(pts-m.base, 0) / 90
+}
+
+func (m *muxer) writeFrame(f frame) {
+	ts := m.ms(f.pts)
+	m.writeSubtitles(ts)
+
+	var duration int64
+	if f.track.kind == trackAudio {
+		duration = samplesPerFrame * 1000 / int64(f.track.sampleRate)
+	}
+	m.writeBlock(f.track, ts, f.keyframe, f.data, duration)
+}
+
+// writeSubtitles writes the subtitle cues starting at or before ts, so
+// they are interleaved with the audio and video in time order.
+func (m *muxer) writeSubtitles(ts int64) {
+	for _, t := range m.subtitles {
+		for ; t.cue < len(t.subtitle.Cues); t.cue++ {
+			cue := t.subtitle.Cues[t.cue]
+			start := cue.Start.Milliseconds()
+			if start > ts {
+				break
+			}
+			m.writeBlock(t, max(start, 0), true, []byte(cue.Text), (cue.End - cue.Start).Milliseconds())
+		}
+	}
+}
+
+func (m *muxer) writeBlock(t *track, ts int64, keyframe bool, data []byte, duration int64) {
+	// A new cluster starts at a video keyframe, or at any audio frame in
+	// files without video, so seeking lands on a decodable frame.
+	seekable := keyframe && (t.kind == trackVideo || !m.hasVideo && t.kind == trackAudio)
+	rel := ts - m.clusterStart
+	if !m.clusterOpen || rel > math.MaxInt16 || rel < math.MinInt16 || seekable && rel >= clusterLength {
+		m.flushCluster()
+		m.clusterStart, m.clusterOpen, rel = ts, true, 0
+		if seekable {
+			m.cues = append(m.cues, cuePoint{time: ts, track: t.number, position: m.offset - m.segmentStart})
+		}
+	}
+
+	block := appendSize(nil, uint64(t.number))
+	block = binary.BigEndian.AppendUint16(block, uint16(int16(rel)))
+	if t.kind == trackSubtitle {
+		block = append(append(block, 0), data...)
+		m.cluster = append(m.cluster, master(idBlockGroup,
+			element(idBlock, block),
+			uintElement(idBlockDuration, uint64(max(duration, 0))),
+		)...)
+	} else {
+		var flags byte
+		if keyframe {
+			flags = 0x80
+		}
+		block = append(append(block, flags), data...)
+		m.cluster = append(m.cluster, element(idSimpleBlock, block)...)
+	}
+	m.end = max(m.end, ts+duration)
+}
+
+func (m *muxer) flushCluster() {
+	if !m.clusterOpen {
+		return
+	}
+	data := append(uintElement(idTimestamp, uint64(m.clusterStart)), m.cluster...)
+	m.write(element(idCluster, data))
+	m.cluster = m.cluster[:0]
+	m.clusterOpen = false
+}
+
+// finish writes what is left, the cues, and patches the sizes, duration
+// and SeekHead reserved by writeHeader.
+func (m *muxer) finish() error {
+	m.writeSubtitles(math.MaxInt64)
+	m.flushCluster()
+
+	if len(m.cues) > 0 {
+		var points [][]byte
+		for _, c := range m.cues {
+			points = append(points, master(idCuePoint,
+				uintElement(idCueTime, uint64(c.time)),
+				master(idCueTrackPositions,
+					uintElement(idCueTrack, uint64(c.track)),
+					uintElement(idCueClusterPosition, uint64(c.position)),
+				),
+			))
+		}
+		m.writeTopLevel(idCues, master(idCues, points...))
+	}
+	if m.err != nil {
+		return m.err
+	}
+
+	m.patch(m.segmentSizePos, appendSizeN(nil, uint64(m.offset-m.segmentStart), 8))
+	m.patch(m.durationPos, binary.BigEndian.AppendUint64(nil, math.Float64bits(float64(m.end))))
+	m.patch(m.seekHeadPos, m.seekHead())
+	return m.err
+}
+
+func (m *muxer) patch(pos int64, b []byte) {
+	if m.err != nil {
+		return
+	}
+	if _, err := m.w.Seek(pos, io.SeekStart); err != nil {
+		m.err = fmt.Errorf("seek: %w", err)
+		return
+	}
+	if _, err := m.w.Write(b); err != nil {
+		m.err = fmt.Errorf("write: %w", err)
+	}
+}
+
+// seekHead returns the SeekHead padded with a Void to exactly
+// seekHeadSize bytes.
+func (m *muxer) seekHead() []byte {
+	var body []byte
+	for _, p := range m.positions {
+		body = append(body, master(idSeek,
+			element(idSeekID, appendID(nil, p.id)),
+			uintElement(idSeekPosition, uint64(p.position)),
+		)...)
+	}
+
+	head := appendID(nil, idSeekHead)
+	sizeLength := len(appendSize(nil, uint64(len(body))))
+	if seekHeadSize-(len(head)+sizeLength+len(body)) == 1 {
+		// A one byte gap cannot hold a Void, so widen the size instead.
+		sizeLength++
+	}
+	head = appendSizeN(head, uint64(len(body)), sizeLength)
+	head = append(head, body...)
+	if pad := seekHeadSize - len(head); pad > 0 {
+		head = append(head, void(pad)...)
+	}
+	return head
+}
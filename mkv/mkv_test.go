@@ -0,0 +1,510 @@
+package mkv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ppvan/nem/internal/tstest"
+	"github.com/ppvan/nem/mpegts"
+)
+
+// ebmlElement is an element read back from a remuxed file. offset is where
+// its header starts, relative to the data it was read from.
+type ebmlElement struct {
+	id     uint32
+	offset int
+	data   []byte
+}
+
+// readVint reads an EBML variable size integer, keeping the length marker
+// for IDs. It reports unknown sizes.
+func readVint(b []byte, keepMarker bool) (value uint64, length int, unknown bool) {
+	if len(b) == 0 || b[0] == 0 {
+		return 0, 0, false
+	}
+	length = 1
+	for b[0]&(0x80>>(length-1)) == 0 {
+		length++
+	}
+	if len(b) < length {
+		return 0, 0, false
+	}
+	first := uint64(b[0])
+	if !keepMarker {
+		first &= 0xFF >> length
+	}
+	value = first
+	allOnes := first == 0xFF>>length
+	for _, c := range b[1:length] {
+		value = value<<8 | uint64(c)
+		allOnes = allOnes && c == 0xFF
+	}
+	return value, length, !keepMarker && allOnes
+}
+
+func readElements(t *testing.T, b []byte) []ebmlElement {
+	t.Helper()
+	var elements []ebmlElement
+	for pos := 0; pos < len(b); {
+		id, idLength, _ := readVint(b[pos:], true)
+		size, sizeLength, unknown := readVint(b[pos+idLength:], false)
+		if idLength == 0 || sizeLength == 0 {
+			t.Fatalf("invalid element header at %d", pos)
+		}
+		if unknown {
+			t.Fatalf("element %X at %d still has an unknown size", id, pos)
+		}
+		start := pos + idLength + sizeLength
+		end := start + int(size)
+		if end > len(b) {
+			t.Fatalf("element %X at %d overruns its parent: %d > %d", id, pos, end, len(b))
+		}
+		elements = append(elements, ebmlElement{id: uint32(id), offset: pos, data: b[start:end]})
+		pos = end
+	}
+	return elements
+}
+
+func find(elements []ebmlElement, id uint32) []ebmlElement {
+	var found []ebmlElement
+	for _, e := range elements {
+		if e.id == id {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+func child(t *testing.T, parent ebmlElement, id uint32) ebmlElement {
+	t.Helper()
+	found := find(readElements(t, parent.data), id)
+	if len(found) == 0 {
+		t.Fatalf("element %X has no child %X", parent.id, id)
+	}
+	return found[0]
+}
+
+func (e ebmlElement) uint() uint64 {
+	var v uint64
+	for _, c := range e.data {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func (e ebmlElement) float() float64 {
+	if len(e.data) == 4 {
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(e.data)))
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64(e.data))
+}
+
+// remuxed is a remuxed file split into its Segment children.
+type remuxed struct {
+	segment []byte
+	top     []ebmlElement
+}
+
+func remux(t *testing.T, ts []byte, meta *Metadata) *remuxed {
+	t.Helper()
+	f, err := os.Create(filepath.Join(t.TempDir(), "out.mkv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := Remux(f, bytes.NewReader(ts), meta); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	top := readElements(t, data)
+	if len(top) != 2 || top[0].id != idEBML || top[1].id != idSegment {
+		t.Fatalf("top level elements = %v, want EBML and Segment", top)
+	}
+	if docType := child(t, top[0], idDocType); string(docType.data) != "matroska" {
+		t.Errorf("DocType = %q", docType.data)
+	}
+	return &remuxed{segment: top[1].data, top: readElements(t, top[1].data)}
+}
+
+// block is a SimpleBlock or the Block of a BlockGroup, with its absolute time.
+type block struct {
+	track    int
+	time     int64
+	keyframe bool
+	data     []byte
+}
+
+func (r *remuxed) blocks(t *testing.T) (clusters []int64, blocks []block) {
+	t.Helper()
+	for _, cluster := range find(r.top, idCluster) {
+		children := readElements(t, cluster.data)
+		base := int64(child(t, cluster, idTimestamp).uint())
+		clusters = append(clusters, base)
+		for _, c := range children {
+			data, keyframe := c.data, false
+			switch c.id {
+			case idSimpleBlock:
+				keyframe = data[3]&0x80 != 0
+			case idBlockGroup:
+				data = child(t, c, idBlock).data
+			default:
+				continue
+			}
+			rel := int16(binary.BigEndian.Uint16(data[1:3]))
+			blocks = append(blocks, block{
+				track:    int(data[0] & 0x7F),
+				time:     base + int64(rel),
+				keyframe: keyframe,
+				data:     data[4:],
+			})
+		}
+	}
+	return clusters, blocks
+}
+
+func TestRemuxRoundTrip(t *testing.T) {
+	const frames, gop = 300, 25 // 12s at 25 fps, a keyframe every second
+	ts, audioFrames := tstest.Stream(tstest.Options{Width: 1280, Height: 720, Frames: frames, GOP: gop})
+	cover := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 32)...)
+	meta := &Metadata{
+		Title:        "Frieren",
+		EpisodeTitle: "Tập 05",
+		Episode:      5,
+		Description:  "A journey.",
+		Genres:       []string{"Fantasy", "Adventure"},
+		Year:         2023,
+		Cover:        cover,
+		Chapters:     []Chapter{{0, "Intro"}, {90 * time.Second, "Part A"}},
+		Subtitles: []Subtitle{{Language: "vie", Name: "Tiếng Việt", Cues: []Cue{
+			{Start: time.Second, End: 3 * time.Second, Text: "Xin chào"},
+			{Start: 6 * time.Second, End: 7 * time.Second, Text: "<i>Tạm biệt</i>"},
+		}}},
+	}
+	r := remux(t, ts, meta)
+
+	// Tracks
+	entries := find(readElements(t, find(r.top, idTracks)[0].data), idTrackEntry)
+	if len(entries) != 3 {
+		t.Fatalf("%d tracks, want video, audio and subtitles", len(entries))
+	}
+	video := child(t, entries[0], idVideo)
+	if w, h := child(t, video, idPixelWidth).uint(), child(t, video, idPixelHeight).uint(); w != 1280 || h != 720 {
+		t.Errorf("video size = %dx%d, want 1280x720", w, h)
+	}
+	if private := child(t, entries[0], idCodecPrivate).data; private[0] != 1 || private[1] != 66 {
+		t.Errorf("AVC configuration = % x, want version 1 and baseline profile", private[:2])
+	}
+	audio := child(t, entries[1], idAudio)
+	if rate, channels := child(t, audio, idSamplingFrequency).float(), child(t, audio, idChannels).uint(); rate != 48000 || channels != 2 {
+		t.Errorf("audio = %v Hz %d channels, want 48000 Hz stereo", rate, channels)
+	}
+	if private := child(t, entries[1], idCodecPrivate).data; !bytes.Equal(private, []byte{0x11, 0x90}) {
+		t.Errorf("AudioSpecificConfig = % x, want 11 90 (AAC LC, 48 kHz, stereo)", private)
+	}
+	if lang := child(t, entries[2], idLanguage).data; string(lang) != "vie" {
+		t.Errorf("subtitle language = %q", lang)
+	}
+
+	// Blocks and clusters
+	clusters, blocks := r.blocks(t)
+	if !slices.Equal(clusters, []int64{0, 5000, 10000}) {
+		t.Errorf("cluster timestamps = %v, want a cluster every %dms at a keyframe", clusters, clusterLength)
+	}
+	var videoBlocks, audioBlocks []block
+	var cues []string
+	for _, b := range blocks {
+		switch b.track {
+		case 1:
+			videoBlocks = append(videoBlocks, b)
+		case 2:
+			audioBlocks = append(audioBlocks, b)
+		case 3:
+			cues = append(cues, string(b.data))
+		}
+	}
+	if len(videoBlocks) != frames || len(audioBlocks) != audioFrames {
+		t.Fatalf("got %d video and %d audio blocks, want %d and %d", len(videoBlocks), len(audioBlocks), frames, audioFrames)
+	}
+	for i, b := range videoBlocks {
+		if want := int64(i * 40); b.time != want {
+			t.Fatalf("video frame %d at %dms, want %dms", i, b.time, want)
+		}
+		if b.keyframe != (i%gop == 0) {
+			t.Errorf("video frame %d keyframe = %v", i, b.keyframe)
+		}
+		// Length prefixed NAL units without the access unit delimiter.
+		if nal := b.data[4] & 0x1F; nal == nalAUD || binary.BigEndian.Uint32(b.data) > uint32(len(b.data)) {
+			t.Fatalf("video frame %d is not AVCC without AUD: % x", i, b.data)
+		}
+	}
+	for i, b := range audioBlocks {
+		if want := int64(i) * 1920 / 90; b.time != want {
+			t.Fatalf("audio frame %d at %dms, want %dms", i, b.time, want)
+		}
+		if !bytes.Equal(b.data, []byte{byte(i), 0x21, 0x10}) {
+			t.Fatalf("audio frame %d = % x, want the raw frame without its ADTS header", i, b.data)
+		}
+	}
+	if !slices.Equal(cues, []string{"Xin chào", "<i>Tạm biệt</i>"}) {
+		t.Errorf("subtitle blocks = %q", cues)
+	}
+
+	// Duration is patched in once the last block is known.
+	lastAudio := int64(audioFrames-1)*1920/90 + samplesPerFrame*1000/48000
+	info := find(r.top, idInfo)[0]
+	if d := child(t, info, idDuration).float(); d != float64(max(lastAudio, int64(frames-1)*40)) {
+		t.Errorf("Duration = %v, want %d", d, lastAudio)
+	}
+	if title := child(t, info, idTitle).data; string(title) != "Frieren - Tập 05" {
+		t.Errorf("Title = %q", title)
+	}
+
+	// Cues point at the clusters starting at keyframes.
+	clusterOffsets := make(map[int]bool)
+	for _, c := range find(r.top, idCluster) {
+		clusterOffsets[c.offset] = true
+	}
+	points := find(readElements(t, find(r.top, idCues)[0].data), idCuePoint)
+	if len(points) != len(clusters) {
+		t.Errorf("%d cue points for %d clusters", len(points), len(clusters))
+	}
+	for _, p := range points {
+		positions := child(t, p, idCueTrackPositions)
+		if pos := child(t, positions, idCueClusterPosition).uint(); !clusterOffsets[int(pos)] {
+			t.Errorf("cue at %dms points to %d, which is not a cluster", child(t, p, idCueTime).uint(), pos)
+		}
+	}
+
+	// Metadata
+	file := child(t, find(r.top, idAttachments)[0], idAttachedFile)
+	if name := child(t, file, idFileName).data; string(name) != "cover.png" {
+		t.Errorf("cover attachment named %q, want cover.png", name)
+	}
+	if data := child(t, file, idFileData).data; !bytes.Equal(data, cover) {
+		t.Error("cover attachment data differs")
+	}
+	tags := map[string]string{}
+	for _, tag := range find(readElements(t, find(r.top, idTags)[0].data), idTag) {
+		target := child(t, child(t, tag, idTargets), idTargetType).data
+		for _, simple := range find(readElements(t, tag.data), idSimpleTag) {
+			tags[string(target)+"/"+string(child(t, simple, idTagName).data)] += string(child(t, simple, idTagString).data) + ";"
+		}
+	}
+	want := map[string]string{
+		"COLLECTION/TITLE":         "Frieren;",
+		"COLLECTION/DESCRIPTION":   "A journey.;",
+		"COLLECTION/GENRE":         "Fantasy;Adventure;",
+		"COLLECTION/DATE_RELEASED": "2023;",
+		"EPISODE/TITLE":            "Tập 05;",
+		"EPISODE/PART_NUMBER":      "5;",
+	}
+	for k, v := range want {
+		if tags[k] != v {
+			t.Errorf("tag %s = %q, want %q", k, tags[k], v)
+		}
+	}
+	chapters := find(readElements(t, child(t, find(r.top, idChapters)[0], idEditionEntry).data), idChapterAtom)
+	if len(chapters) != 2 || child(t, chapters[1], idChapterTimeStart).uint() != uint64(90*time.Second) {
+		t.Errorf("chapters = %v", chapters)
+	}
+}
+
+func TestRemuxSeekHead(t *testing.T) {
+	ts, _ := tstest.Stream(tstest.Options{Width: 640, Height: 360, Frames: 50, GOP: 25})
+	r := remux(t, ts, &Metadata{Title: "Title", Cover: []byte{0xFF, 0xD8, 0xFF}})
+
+	// The SeekHead and the Void padding it fill exactly the reserved space.
+	if r.top[0].id != idSeekHead || r.top[1].id != idVoid {
+		t.Fatalf("segment starts with %X, %X, want SeekHead and Void", r.top[0].id, r.top[1].id)
+	}
+	if r.top[2].offset != seekHeadSize {
+		t.Errorf("first element after the SeekHead at %d, want %d", r.top[2].offset, seekHeadSize)
+	}
+
+	seen := map[uint32]bool{}
+	for _, seek := range find(readElements(t, r.top[0].data), idSeek) {
+		id, _, _ := readVint(child(t, seek, idSeekID).data, true)
+		pos := int(child(t, seek, idSeekPosition).uint())
+		target := readElements(t, r.segment[pos:])[0]
+		if target.id != uint32(id) {
+			t.Errorf("SeekHead points %X at %d, which holds %X", id, pos, target.id)
+		}
+		seen[uint32(id)] = true
+	}
+	for _, id := range []uint32{idInfo, idTracks, idAttachments, idTags, idCues} {
+		if !seen[id] {
+			t.Errorf("SeekHead has no entry for %X", id)
+		}
+	}
+	if seen[idChapters] {
+		t.Error("SeekHead lists chapters the file does not have")
+	}
+}
+
+func TestSeekHeadPadding(t *testing.T) {
+	repeat := func(n int, position int64) []int64 { return slices.Repeat([]int64{position}, n) }
+	// Every Seek is 13 bytes plus its position. Past 126 bytes of entries the
+	// SeekHead size takes two bytes.
+	tests := []struct {
+		name      string
+		positions []int64
+		void      int
+	}{
+		{"one entry", []int64{1}, 141},
+		{"every top level element", []int64{0xA0, 0x1000, 0x2000, 0x90000, 0x10000000, 0x800000000}, 60},
+		{"two byte size", repeat(10, 1), 14},
+		{"empty void", append(repeat(8, 0x100), repeat(2, 0x10000)...), 2},
+		{"one byte gap", append(repeat(7, 0x100), repeat(3, 0x10000)...), 0},
+		{"no gap", repeat(11, 1), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &muxer{}
+			for _, position := range tt.positions {
+				m.positions = append(m.positions, seekEntry{id: idCues, position: position})
+			}
+			head := m.seekHead()
+			if len(head) != seekHeadSize {
+				t.Fatalf("SeekHead is %d bytes, want %d", len(head), seekHeadSize)
+			}
+			elements := readElements(t, head)
+			if elements[0].id != idSeekHead {
+				t.Fatalf("first element %X, want SeekHead", elements[0].id)
+			}
+			for i, seek := range find(readElements(t, elements[0].data), idSeek) {
+				if got := int64(child(t, seek, idSeekPosition).uint()); got != tt.positions[i] {
+					t.Errorf("entry %d position = %d, want %d", i, got, tt.positions[i])
+				}
+			}
+			void := 0
+			for _, e := range elements[1:] {
+				if e.id != idVoid {
+					t.Errorf("padding element %X, want Void", e.id)
+				}
+				void += seekHeadSize - e.offset
+			}
+			if void != tt.void {
+				t.Errorf("Void is %d bytes, want %d", void, tt.void)
+			}
+		})
+	}
+}
+
+func TestUnwrap(t *testing.T) {
+	const wrap = 1 << 33
+	m := &muxer{}
+	in := []int64{wrap - 90000, wrap - 3600, 100, 3700, wrap - 1800, 7300, mpegts.NoTimestamp, 10900}
+	want := []int64{wrap - 90000, wrap - 3600, wrap + 100, wrap + 3700, wrap - 1800, wrap + 7300, mpegts.NoTimestamp, wrap + 10900}
+	for i, pts := range in {
+		if got := m.unwrap(pts); got != want[i] {
+			t.Errorf("unwrap(%d) = %d, want %d", pts, got, want[i])
+		}
+	}
+}
+
+func TestRemuxAcrossPTSWrap(t *testing.T) {
+	plain, _ := tstest.Stream(tstest.Options{Width: 640, Height: 360, Frames: 300, GOP: 25})
+	wrapped, _ := tstest.Stream(tstest.Options{Width: 640, Height: 360, Frames: 300, GOP: 25, StartPTS: 1<<33 - 5*90000})
+
+	want, wantBlocks := remux(t, plain, nil).blocks(t)
+	got, gotBlocks := remux(t, wrapped, nil).blocks(t)
+	if !slices.Equal(got, want) {
+		t.Errorf("clusters across the wrap = %v, want %v", got, want)
+	}
+	if len(gotBlocks) != len(wantBlocks) {
+		t.Fatalf("%d blocks across the wrap, want %d", len(gotBlocks), len(wantBlocks))
+	}
+	for i := range gotBlocks {
+		if gotBlocks[i].time != wantBlocks[i].time {
+			t.Fatalf("block %d at %dms across the wrap, want %dms", i, gotBlocks[i].time, wantBlocks[i].time)
+		}
+	}
+}
+
+func TestRemuxVideoOnly(t *testing.T) {
+	ts, _ := tstest.Stream(tstest.Options{Width: 1920, Height: 1080, Frames: 50, GOP: 25, NoAudio: true})
+	r := remux(t, ts, nil)
+
+	entries := find(readElements(t, find(r.top, idTracks)[0].data), idTrackEntry)
+	if len(entries) != 1 {
+		t.Fatalf("%d tracks, want only video", len(entries))
+	}
+	video := child(t, entries[0], idVideo)
+	if h := child(t, video, idPixelHeight).uint(); h != 1080 {
+		t.Errorf("height = %d, want the cropped 1080", h)
+	}
+	if tags := find(r.top, idTags); len(tags) != 0 {
+		t.Error("tags written without metadata")
+	}
+}
+
+func TestRemuxNoStreams(t *testing.T) {
+	ts := append(tstest.PAT(), tstest.PMT()...)
+	f, err := os.Create(filepath.Join(t.TempDir(), "out.mkv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := Remux(f, bytes.NewReader(ts), nil); !errors.Is(err, ErrNoStreams) {
+		t.Errorf("err = %v, want ErrNoStreams", err)
+	}
+}
+
+func TestSPSDimensions(t *testing.T) {
+	for _, size := range [][2]int{{1280, 720}, {1920, 1080}, {854, 480}, {640, 360}} {
+		w, h, err := spsDimensions(tstest.SPS(size[0], size[1]))
+		if err != nil || w != size[0] || h != size[1] {
+			t.Errorf("spsDimensions(%dx%d) = %dx%d, %v", size[0], size[1], w, h, err)
+		}
+	}
+	if _, _, err := spsDimensions([]byte{0x67, 66}); err == nil {
+		t.Error("truncated SPS parsed")
+	}
+}
+
+func TestSplitADTS(t *testing.T) {
+	a := tstest.ADTS(tstest.SampleRateIndex48k, 2, []byte{1, 2, 3})
+	b := tstest.ADTS(tstest.SampleRateIndex48k, 2, []byte{4, 5})
+	stream := append(append(append([]byte{0x00, 0x12}, a...), b...), b[:4]...)
+
+	frames, header, rest := splitADTS(stream)
+	if len(frames) != 2 || !bytes.Equal(frames[0], []byte{1, 2, 3}) || !bytes.Equal(frames[1], []byte{4, 5}) {
+		t.Errorf("frames = % x", frames)
+	}
+	if header.sampleRate() != 48000 || header.channels != 2 {
+		t.Errorf("header = %+v", header)
+	}
+	if !bytes.Equal(rest, b[:4]) {
+		t.Errorf("rest = % x, want the incomplete frame", rest)
+	}
+}
+
+func TestParseSubtitles(t *testing.T) {
+	srt := "\ufeff1\r\n00:00:01,000 --> 00:00:02,500\r\nHello\r\nworld\r\n\r\n2\r\n00:01:02,345 --> 00:01:04,000\r\nBye\r\n"
+	vtt := "WEBVTT\n\nNOTE skipped\n\nintro\n00:01.000 --> 00:02.500 align:start\nHello\nworld\n\n01:02.345 --> 01:04.000\nBye\n"
+	want := []Cue{
+		{time.Second, 2500 * time.Millisecond, "Hello\nworld"},
+		{62345 * time.Millisecond, 64 * time.Second, "Bye"},
+	}
+	for name, input := range map[string]string{"srt": srt, "vtt": vtt} {
+		cues, err := ParseSubtitles(strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(cues, want) {
+			t.Errorf("%s cues = %+v, want %+v", name, cues, want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package mkv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cue is one subtitle entry.
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// Subtitle is a text subtitle track.
+type Subtitle struct {
+	// Language is an ISO 639-2 code such as "vie"; empty means undetermined.
+	Language string
+	Name     string
+	Cues     []Cue
+}
+
+// "00:01:02,345 --> 00:01:04,000" in SRT, "01:02.345 --> 01:04.000" in
+// WebVTT, where the hours are optional and cue settings may follow.
+var cueTimingRegex = regexp.MustCompile(`^((?:\d+:)?\d{2}:\d{2}[.,]\d{3})\s+-->\s+((?:\d+:)?\d{2}:\d{2}[.,]\d{3})`)
+
+// ParseSubtitles reads cues from an SRT or WebVTT file. Formatting tags are
+// kept as they are, which players of S_TEXT/UTF8 tracks understand.
+func ParseSubtitles(r io.Reader) ([]Cue, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var cues []Cue
+	var current *Cue
+	var text []string
+	finish := func() {
+		if current != nil && len(text) > 0 {
+			current.Text = strings.Join(text, "\n")
+			cues = append(cues, *current)
+		}
+		current, text = nil, nil
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		line = strings.TrimPrefix(line, "\uFEFF") // byte order mark
+
+		if m := cueTimingRegex.FindStringSubmatch(line); m != nil {
+			finish()
+			start, err := parseCueTime(m[1])
+			if err != nil {
+				return nil, err
+			}
+			end, err := parseCueTime(m[2])
+			if err != nil {
+				return nil, err
+			}
+			current = &Cue{Start: start, End: end}
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			finish()
+			continue
+		}
+		if current != nil {
+			text = append(text, line)
+		}
+		// Anything else is a cue number, a cue identifier, the WEBVTT
+		// header or a NOTE/STYLE block.
+	}
+	finish()
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read subtitles: %w", err)
+	}
+	return cues, nil
+}
+
+// parseCueTime parses "hh:mm:ss,mmm", "hh:mm:ss.mmm" or "mm:ss.mmm".
+func parseCueTime(s string) (time.Duration, error) {
+	s = strings.Replace(s, ",", ".", 1)
+	parts := strings.Split(s, ":")
+	var d time.Duration
+	for _, part := range parts[:len(parts)-1] {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, fmt.Errorf("invalid cue time %q", s)
+		}
+		d = d*60 + time.Duration(n)
+	}
+	seconds, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cue time %q", s)
+	}
+	return d*60*time.Second + time.Duration(seconds*float64(time.Second)).Round(time.Millisecond), nil
+}
@@ -0,0 +1,53 @@
+package mpegts
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var (
+	ErrAlignment = errors.New("not a whole number of packets")
+	ErrNoPAT     = errors.New("no PAT")
+	ErrNoPMT     = errors.New("no PMT")
+)
+
+// Check verifies that b is a self-contained piece of a transport stream, as
+// every HLS segment must be: a whole number of packets, each starting with
+// the sync byte, carrying a PAT and the PMT it points to.
+func Check(b []byte) error {
+	if len(b) == 0 || len(b)%PacketSize != 0 {
+		return fmt.Errorf("%w: %d bytes", ErrAlignment, len(b))
+	}
+
+	var pmts []uint16
+	var pat, pmt bool
+	for i := 0; i < len(b); i += PacketSize {
+		p, err := ParsePacket(b[i : i+PacketSize])
+		if err != nil {
+			return fmt.Errorf("packet %d: %w", i/PacketSize, err)
+		}
+		if !p.Start {
+			continue
+		}
+		switch {
+		case p.PID == 0:
+			if pids := pmtPIDs(p.Payload); len(pids) > 0 {
+				pat = true
+				pmts = append(pmts, pids...)
+			}
+		case slices.Contains(pmts, p.PID):
+			if _, ok := section(p.Payload, 0x02); ok {
+				pmt = true
+			}
+		}
+	}
+
+	switch {
+	case !pat:
+		return ErrNoPAT
+	case !pmt:
+		return ErrNoPMT
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package mpegts
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ppvan/nem/internal/tstest"
+)
+
+func TestCheck(t *testing.T) {
+	header := append(tstest.PAT(), tstest.PMT()...)
+	stream, _ := tstest.Stream(tstest.Options{Width: 640, Height: 360, Frames: 10, GOP: 10})
+	video := tstest.Packetize(tstest.VideoPID, tstest.PES(0xE0, 0, []byte{1, 2, 3}))
+
+	badSync := append([]byte{}, header...)
+	badSync[PacketSize] = 0x00
+
+	// The PMT on a PID the PAT does not point to.
+	strayPMT := tstest.PMT()
+	strayPMT[1], strayPMT[2] = 0x50, 0x01
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"PAT and PMT", header, nil},
+		{"whole stream", stream, nil},
+		{"empty", nil, ErrAlignment},
+		{"cut short", stream[:len(stream)-1], ErrAlignment},
+		{"trailing byte", append(append([]byte{}, header...), 0x47), ErrAlignment},
+		{"missing sync byte", badSync, ErrSync},
+		{"no PAT", append(tstest.PMT(), video...), ErrNoPAT},
+		{"no PMT", append(tstest.PAT(), video...), ErrNoPMT},
+		{"PMT on another PID", append(tstest.PAT(), strayPMT...), ErrNoPMT},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Check(tt.data); !errors.Is(err, tt.err) {
+				t.Errorf("Check = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
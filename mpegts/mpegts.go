@@ -0,0 +1,298 @@
+package mpegts
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+)
+
+// PacketSize is the size of a transport stream packet.
+const PacketSize = 188
+
+// SyncByte starts every transport stream packet.
+const SyncByte = 0x47
+
+// Stream types announced in the PMT.
+const (
+	StreamTypeAAC  = 0x0F
+	StreamTypeH264 = 0x1B
+)
+
+// NoTimestamp marks a missing PTS or DTS.
+const NoTimestamp = -1
+
+var ErrSync = errors.New("missing sync byte")
+
+// Packet is the part of a transport stream packet the demuxer needs.
+type Packet struct {
+	PID uint16
+	// Start is the payload_unit_start_indicator: a new PES packet or
+	// section begins in this packet.
+	Start   bool
+	Payload []byte
+}
+
+// ParsePacket parses one PacketSize long transport stream packet. The
+// payload aliases b.
+func ParsePacket(b []byte) (Packet, error) {
+	if len(b) != PacketSize || b[0] != SyncByte {
+		return Packet{}, ErrSync
+	}
+
+	p := Packet{
+		PID:   uint16(b[1]&0x1F)<<8 | uint16(b[2]),
+		Start: b[1]&0x40 != 0,
+	}
+
+	control := b[3] >> 4 & 0x3
+	offset := 4
+	if control&0x2 != 0 { // adaptation field
+		offset += 1 + int(b[4])
+	}
+	if control&0x1 != 0 && offset < PacketSize {
+		p.Payload = b[offset:]
+	}
+	return p, nil
+}
+
+// Stream is an elementary stream listed in the PMT.
+type Stream struct {
+	PID  uint16
+	Type byte
+}
+
+// PES is a reassembled packetized elementary stream packet.
+type PES struct {
+	PID  uint16
+	Type byte
+	// PTS and DTS are in 90 kHz units, or NoTimestamp when absent.
+	PTS  int64
+	DTS  int64
+	Data []byte
+}
+
+// Demuxer splits a transport stream into the PES packets of the streams
+// announced by its PMT.
+type Demuxer struct {
+	r       *bufio.Reader
+	packet  [PacketSize]byte
+	pmtPIDs map[uint16]bool
+	streams []Stream
+	pending map[uint16]*bytes.Buffer
+	queue   []*PES
+	eof     bool
+}
+
+// NewDemuxer returns a demuxer reading the transport stream r.
+func NewDemuxer(r io.Reader) *Demuxer {
+	return &Demuxer{
+		r:       bufio.NewReaderSize(r, 64*PacketSize),
+		pmtPIDs: make(map[uint16]bool),
+		pending: make(map[uint16]*bytes.Buffer),
+	}
+}
+
+// Streams returns the elementary streams seen so far. It is complete once
+// Next has returned the first PES packet.
+func (d *Demuxer) Streams() []Stream {
+	return d.streams
+}
+
+// Next returns the next complete PES packet, or io.EOF at the end of the
+// stream.
+func (d *Demuxer) Next() (*PES, error) {
+	for len(d.queue) == 0 {
+		if d.eof {
+			return nil, io.EOF
+		}
+		if err := d.readPacket(); err != nil {
+			return nil, err
+		}
+	}
+
+	pes := d.queue[0]
+	d.queue = d.queue[1:]
+	return pes, nil
+}
+
+func (d *Demuxer) readPacket() error {
+	b := d.packet[:]
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			d.flush()
+			return nil
+		}
+		return err
+	}
+
+	// Skip garbage between packets, e.g. a truncated segment.
+	for b[0] != SyncByte {
+		i := bytes.IndexByte(b[1:], SyncByte)
+		if i < 0 {
+			i = PacketSize - 1
+		}
+		n := copy(b, b[i+1:])
+		if _, err := io.ReadFull(d.r, b[n:]); err != nil {
+			d.flush()
+			return nil
+		}
+	}
+
+	p, err := ParsePacket(b)
+	if err != nil {
+		return err
+	}
+	switch {
+	case p.PID == 0:
+		if p.Start {
+			d.parsePAT(p.Payload)
+		}
+	case d.pmtPIDs[p.PID]:
+		if p.Start {
+			d.parsePMT(p.Payload)
+		}
+	default:
+		d.appendPES(p)
+	}
+	return nil
+}
+
+func (d *Demuxer) appendPES(p Packet) {
+	i := slices.IndexFunc(d.streams, func(s Stream) bool { return s.PID == p.PID })
+	if i < 0 {
+		return
+	}
+
+	buf := d.pending[p.PID]
+	if p.Start {
+		if buf != nil && buf.Len() > 0 {
+			d.emit(d.streams[i], buf.Bytes())
+		}
+		buf = new(bytes.Buffer)
+		d.pending[p.PID] = buf
+	}
+	if buf != nil {
+		// Packets before the first payload start belong to a PES packet
+		// that began before the stream did and are dropped.
+		buf.Write(p.Payload)
+	}
+}
+
+// flush emits the PES packets still being assembled at the end of the
+// stream.
+func (d *Demuxer) flush() {
+	d.eof = true
+	for _, s := range d.streams {
+		if buf := d.pending[s.PID]; buf != nil && buf.Len() > 0 {
+			d.emit(s, buf.Bytes())
+		}
+	}
+	clear(d.pending)
+}
+
+func (d *Demuxer) emit(s Stream, data []byte) {
+	pes, ok := parsePES(data)
+	if !ok {
+		return
+	}
+	pes.PID, pes.Type = s.PID, s.Type
+	d.queue = append(d.queue, pes)
+}
+
+// section returns the table section that starts in payload, skipping the
+// pointer field, and its body after the common header.
+func section(payload []byte, tableID byte) ([]byte, bool) {
+	if len(payload) < 1 || len(payload) < 1+int(payload[0]) {
+		return nil, false
+	}
+	s := payload[1+int(payload[0]):]
+	if len(s) < 8 || s[0] != tableID {
+		return nil, false
+	}
+	length := int(s[1]&0x0F)<<8 | int(s[2])
+	if length < 9 || len(s) < 3+length {
+		return nil, false
+	}
+	// Drop the 5 byte table header and the trailing CRC.
+	return s[8 : 3+length-4], true
+}
+
+// parsePAT records the PMT PIDs of the programs in the PAT.
+func (d *Demuxer) parsePAT(payload []byte) {
+	for _, pid := range pmtPIDs(payload) {
+		d.pmtPIDs[pid] = true
+	}
+}
+
+// pmtPIDs returns the PMT PIDs listed by the PAT starting in payload.
+func pmtPIDs(payload []byte) []uint16 {
+	body, ok := section(payload, 0x00)
+	if !ok {
+		return nil
+	}
+	var pids []uint16
+	for ; len(body) >= 4; body = body[4:] {
+		program := uint16(body[0])<<8 | uint16(body[1])
+		pid := uint16(body[2]&0x1F)<<8 | uint16(body[3])
+		if program != 0 { // 0 is the network PID
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+// parsePMT records the elementary streams of a program.
+func (d *Demuxer) parsePMT(payload []byte) {
+	body, ok := section(payload, 0x02)
+	if !ok || len(body) < 4 {
+		return
+	}
+	infoLength := int(body[2]&0x0F)<<8 | int(body[3])
+	if len(body) < 4+infoLength {
+		return
+	}
+	for body = body[4+infoLength:]; len(body) >= 5; {
+		s := Stream{
+			Type: body[0],
+			PID:  uint16(body[1]&0x1F)<<8 | uint16(body[2]),
+		}
+		esLength := int(body[3]&0x0F)<<8 | int(body[4])
+		if !slices.Contains(d.streams, s) {
+			d.streams = append(d.streams, s)
+		}
+		if len(body) < 5+esLength {
+			break
+		}
+		body = body[5+esLength:]
+	}
+}
+
+func parsePES(b []byte) (*PES, bool) {
+	if len(b) < 9 || b[0] != 0 || b[1] != 0 || b[2] != 1 {
+		return nil, false
+	}
+	pes := &PES{PTS: NoTimestamp, DTS: NoTimestamp}
+
+	flags := b[7] >> 6
+	headerEnd := 9 + int(b[8])
+	if len(b) < headerEnd {
+		return nil, false
+	}
+	if flags&0x2 != 0 && headerEnd >= 14 {
+		pes.PTS = timestamp(b[9:14])
+		pes.DTS = pes.PTS
+	}
+	if flags == 0x3 && headerEnd >= 19 {
+		pes.DTS = timestamp(b[14:19])
+	}
+	pes.Data = b[headerEnd:]
+	return pes, true
+}
+
+// timestamp decodes a 33 bit PTS or DTS field.
+func timestamp(b []byte) int64 {
+	return int64(b[0]>>1&0x07)<<30 | int64(b[1])<<22 | int64(b[2]>>1)<<15 |
+		int64(b[3])<<7 | int64(b[4]>>1)
+}
@@ -0,0 +1,129 @@
+package mpegts
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/ppvan/nem/internal/tstest"
+)
+
+func readAll(t *testing.T, r io.Reader) (*Demuxer, []*PES) {
+	t.Helper()
+	d := NewDemuxer(r)
+	var packets []*PES
+	for {
+		pes, err := d.Next()
+		if errors.Is(err, io.EOF) {
+			return d, packets
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		packets = append(packets, pes)
+	}
+}
+
+func TestParsePacket(t *testing.T) {
+	tests := []struct {
+		name    string
+		packet  []byte
+		pid     uint16
+		start   bool
+		payload []byte
+		err     error
+	}{
+		{"payload only", tstest.Packetize(0x100, bytes.Repeat([]byte{7}, 184)), 0x100, true, bytes.Repeat([]byte{7}, 184), nil},
+		{"adaptation field", tstest.Packet(0x1FFF, false, []byte{1, 2, 3}), 0x1FFF, false, []byte{1, 2, 3}, nil},
+		{"adaptation field only", append([]byte{0x47, 0x01, 0x00, 0x20, 183}, make([]byte, 183)...), 0x100, false, nil, nil},
+		{"short", tstest.Packet(0, true, nil)[:100], 0, false, nil, ErrSync},
+		{"no sync byte", append([]byte{0x48}, tstest.Packet(0, true, nil)[1:]...), 0, false, nil, ErrSync},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ParsePacket(tt.packet)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if p.PID != tt.pid || p.Start != tt.start || !bytes.Equal(p.Payload, tt.payload) {
+				t.Errorf("packet = %d %v % x, want %d %v % x", p.PID, p.Start, p.Payload, tt.pid, tt.start, tt.payload)
+			}
+		})
+	}
+}
+
+func TestDemuxer(t *testing.T) {
+	const frames = 50
+	ts, audioFrames := tstest.Stream(tstest.Options{Width: 640, Height: 360, Frames: frames, GOP: 25, StartPTS: 900})
+	d, packets := readAll(t, bytes.NewReader(ts))
+
+	want := []Stream{{tstest.VideoPID, StreamTypeH264}, {tstest.AudioPID, StreamTypeAAC}}
+	if !slices.Equal(d.Streams(), want) {
+		t.Errorf("streams = %v, want %v", d.Streams(), want)
+	}
+
+	var video, audio int
+	for _, pes := range packets {
+		switch pes.PID {
+		case tstest.VideoPID:
+			au := tstest.AccessUnit(tstest.SPS(640, 360), video%25 == 0, byte(video))
+			if !bytes.Equal(pes.Data, au) {
+				t.Fatalf("video PES %d data = % x, want % x", video, pes.Data, au)
+			}
+			if want := 900 + int64(video)*3600; pes.PTS != want || pes.DTS != want {
+				t.Errorf("video PES %d PTS/DTS = %d/%d, want %d", video, pes.PTS, pes.DTS, want)
+			}
+			video++
+		case tstest.AudioPID:
+			if pes.Type != StreamTypeAAC {
+				t.Errorf("audio PES type = %#x", pes.Type)
+			}
+			audio++
+		}
+	}
+	if video != frames {
+		t.Errorf("%d video PES packets, want %d", video, frames)
+	}
+	if want := audioFrames / tstest.AudioFramesPerPES; audio != want {
+		t.Errorf("%d audio PES packets, want %d", audio, want)
+	}
+}
+
+func TestDemuxerSkipsGarbage(t *testing.T) {
+	pes := tstest.PES(0xE0, 1<<33-1, bytes.Repeat([]byte{0xAB}, 300))
+	var ts []byte
+	ts = append(ts, tstest.PAT()...)
+	ts = append(ts, tstest.PMT()...)
+	// Stray bytes between packets, as left by a broken segment.
+	ts = append(ts, 0x00, 0x12, 0xFF, 0xFF, 0xFF)
+	ts = append(ts, tstest.Packetize(tstest.VideoPID, pes)...)
+	// A continuation without a start belongs to nothing and is dropped.
+	ts = append(ts, tstest.Packet(tstest.AudioPID, false, []byte{1, 2, 3})...)
+
+	_, packets := readAll(t, bytes.NewReader(ts))
+	if len(packets) != 1 {
+		t.Fatalf("%d PES packets, want 1", len(packets))
+	}
+	if packets[0].PTS != 1<<33-1 {
+		t.Errorf("PTS = %d, want the largest 33 bit timestamp", packets[0].PTS)
+	}
+	if !bytes.Equal(packets[0].Data, bytes.Repeat([]byte{0xAB}, 300)) {
+		t.Error("PES data differs after resync")
+	}
+}
+
+func TestDemuxerIgnoresUnannouncedStreams(t *testing.T) {
+	var ts []byte
+	ts = append(ts, tstest.Packetize(tstest.VideoPID, tstest.PES(0xE0, 0, []byte{1}))...)
+	ts = append(ts, tstest.PAT()...)
+	ts = append(ts, tstest.PMT()...)
+	ts = append(ts, tstest.Packetize(0x200, tstest.PES(0xE0, 0, []byte{2}))...)
+	ts = append(ts, tstest.Packetize(tstest.VideoPID, tstest.PES(0xE0, 0, []byte{3}))...)
+
+	_, packets := readAll(t, bytes.NewReader(ts))
+	if len(packets) != 1 || !bytes.Equal(packets[0].Data, []byte{3}) {
+		t.Errorf("packets = %v, want only the one after the PMT", packets)
+	}
+}
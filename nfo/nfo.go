@@ -0,0 +1,176 @@
+package nfo
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ppvan/nem/extractor"
+)
+
+// UniqueIDType names the site IDs in <uniqueid> elements.
+const UniqueIDType = "animevietsub"
+
+// TVShow is the tvshow.nfo document read by Kodi, Jellyfin and Emby.
+type TVShow struct {
+	XMLName       xml.Name `xml:"tvshow"`
+	Title         string   `xml:"title"`
+	OriginalTitle string   `xml:"originaltitle,omitempty"`
+	Plot          string   `xml:"plot,omitempty"`
+	Ratings       *Ratings `xml:"ratings,omitempty"`
+	Year          int      `xml:"year,omitempty"`
+	Status        string   `xml:"status,omitempty"`
+	Studios       []string `xml:"studio,omitempty"`
+	Genres        []string `xml:"genre,omitempty"`
+	Tags          []string `xml:"tag,omitempty"` // alternative titles
+	UniqueID      UniqueID `xml:"uniqueid"`
+	Thumbs        []Thumb  `xml:"thumb,omitempty"`
+	Fanart        *Fanart  `xml:"fanart,omitempty"`
+	Episodes      int      `xml:"episode,omitempty"`
+	DateAdded     string   `xml:"dateadded,omitempty"`
+}
+
+// Episode is the per-episode <episodedetails> document stored next to the
+// video with the same base name.
+type Episode struct {
+	XMLName   xml.Name `xml:"episodedetails"`
+	Title     string   `xml:"title"`
+	ShowTitle string   `xml:"showtitle"`
+	Season    int      `xml:"season"`
+	Episode   int      `xml:"episode"`
+	Runtime   int      `xml:"runtime,omitempty"` // minutes
+	UniqueID  UniqueID `xml:"uniqueid"`
+	DateAdded string   `xml:"dateadded,omitempty"`
+}
+
+type Ratings struct {
+	Rating []Rating `xml:"rating"`
+}
+
+type Rating struct {
+	Name    string  `xml:"name,attr"`
+	Max     int     `xml:"max,attr"`
+	Default bool    `xml:"default,attr"`
+	Value   float64 `xml:"value"`
+}
+
+type UniqueID struct {
+	Type    string `xml:"type,attr"`
+	Default bool   `xml:"default,attr"`
+	Value   string `xml:",chardata"`
+}
+
+type Thumb struct {
+	Aspect string `xml:"aspect,attr,omitempty"`
+	URL    string `xml:",chardata"`
+}
+
+type Fanart struct {
+	Thumbs []Thumb `xml:"thumb"`
+}
+
+// dateAdded is the timestamp format Kodi uses in <dateadded>.
+const dateAdded = time.DateTime
+
+// NewTVShow maps scraped details to a tvshow.nfo document.
+func NewTVShow(details *extractor.AnimeDetail) *TVShow {
+	show := &TVShow{
+		Title:         details.Title,
+		OriginalTitle: details.Subtitle,
+		Plot:          details.Description,
+		Year:          details.Year,
+		Genres:        details.Genres,
+		Tags:          details.AltTitles,
+		UniqueID:      UniqueID{Type: UniqueIDType, Default: true, Value: strconv.Itoa(details.Id)},
+		Episodes:      details.TotalEpisodes,
+		DateAdded:     time.Now().Format(dateAdded),
+	}
+	if details.Rating > 0 {
+		show.Ratings = &Ratings{Rating: []Rating{{Name: UniqueIDType, Max: 10, Default: true, Value: details.Rating}}}
+	}
+	if details.Studio != "" {
+		show.Studios = strings.Split(details.Studio, ", ")
+	}
+	switch details.Status {
+	case extractor.StatusOngoing, extractor.StatusUpcoming:
+		show.Status = "Continuing"
+	case extractor.StatusCompleted:
+		show.Status = "Ended"
+	}
+	if details.Poster != "" {
+		show.Thumbs = append(show.Thumbs, Thumb{Aspect: "poster", URL: details.Poster})
+	}
+	if details.Banner != "" {
+		show.Fanart = &Fanart{Thumbs: []Thumb{{URL: details.Banner}}}
+	}
+	return show
+}
+
+// NewEpisode maps an episode to an <episodedetails> document. number is the
+// position of the episode in the show, starting at 1.
+func NewEpisode(details *extractor.AnimeDetail, episode extractor.Episode, season, number int, runtime time.Duration) *Episode {
+	return &Episode{
+		Title:     episode.Title,
+		ShowTitle: details.Title,
+		Season:    season,
+		Episode:   number,
+		Runtime:   int(runtime.Round(time.Minute) / time.Minute),
+		UniqueID:  UniqueID{Type: UniqueIDType, Default: true, Value: episode.Id},
+		DateAdded: time.Now().Format(dateAdded),
+	}
+}
+
+// ShowDir is the folder of a show below root, named "Title (Year)" as media
+// servers expect.
+func ShowDir(root string, details *extractor.AnimeDetail) string {
+	name := SanitizeName(details.Title)
+	if details.Year != 0 {
+		name = fmt.Sprintf("%s (%d)", name, details.Year)
+	}
+	return filepath.Join(root, name)
+}
+
+// SeasonDir is the folder of a season inside a show folder.
+func SeasonDir(showDir string, season int) string {
+	return filepath.Join(showDir, fmt.Sprintf("Season %02d", season))
+}
+
+// EpisodeName is the base file name of an episode, e.g. "Title S01E05".
+func EpisodeName(details *extractor.AnimeDetail, season, number int) string {
+	return fmt.Sprintf("%s S%02dE%02d", SanitizeName(details.Title), season, number)
+}
+
+// SanitizeName replaces characters that are not allowed in file names on
+// common file systems.
+func SanitizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		if r < 0x20 {
+			return -1
+		}
+		return r
+	}, name)
+	return strings.TrimRight(strings.TrimSpace(name), ".")
+}
+
+// Write stores doc as an indented XML file at path.
+func Write(path string, doc any) error {
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", filepath.Base(path), err)
+	}
+	data = append([]byte(xml.Header), data...)
+	data = append(data, '\n')
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package nfo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ppvan/nem/extractor"
+)
+
+var details = &extractor.AnimeDetail{
+	Id:            5124,
+	Title:         `Tom & Jerry <"Special">`,
+	Subtitle:      "トムとジェリー",
+	AltTitles:     []string{"Tom and Jerry"},
+	Description:   "Cat & mouse chase <again>.\nA \"classic\" since 1940's.",
+	Rating:        8.5,
+	Poster:        "https://cdn.example/poster.jpg?w=300&h=450",
+	Banner:        "https://cdn.example/banner.jpg",
+	Genres:        []string{"Hài Hước", "Kids & Family"},
+	Studio:        "MGM, Hanna-Barbera",
+	Year:          1940,
+	Status:        extractor.StatusCompleted,
+	TotalEpisodes: 161,
+}
+
+// writeFile encodes doc with Write and returns the file contents.
+func writeFile(t *testing.T, doc any) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "doc.nfo")
+	if err := Write(path, doc); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestNewTVShow(t *testing.T) {
+	show := NewTVShow(details)
+	if _, err := time.Parse(dateAdded, show.DateAdded); err != nil {
+		t.Errorf("dateadded %q: %v", show.DateAdded, err)
+	}
+	show.DateAdded = "2024-01-02 03:04:05"
+
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<tvshow>
+  <title>Tom &amp; Jerry &lt;&#34;Special&#34;&gt;</title>
+  <originaltitle>トムとジェリー</originaltitle>
+  <plot>Cat &amp; mouse chase &lt;again&gt;.&#xA;A &#34;classic&#34; since 1940&#39;s.</plot>
+  <ratings>
+    <rating name="animevietsub" max="10" default="true">
+      <value>8.5</value>
+    </rating>
+  </ratings>
+  <year>1940</year>
+  <status>Ended</status>
+  <studio>MGM</studio>
+  <studio>Hanna-Barbera</studio>
+  <genre>Hài Hước</genre>
+  <genre>Kids &amp; Family</genre>
+  <tag>Tom and Jerry</tag>
+  <uniqueid type="animevietsub" default="true">5124</uniqueid>
+  <thumb aspect="poster">https://cdn.example/poster.jpg?w=300&amp;h=450</thumb>
+  <fanart>
+    <thumb>https://cdn.example/banner.jpg</thumb>
+  </fanart>
+  <episode>161</episode>
+  <dateadded>2024-01-02 03:04:05</dateadded>
+</tvshow>
+`
+	if got := writeFile(t, show); got != want {
+		t.Errorf("tvshow.nfo =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestNewTVShowMinimal(t *testing.T) {
+	show := NewTVShow(&extractor.AnimeDetail{Id: 1, Title: "Mushishi", Status: extractor.StatusOngoing})
+	show.DateAdded = ""
+
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<tvshow>
+  <title>Mushishi</title>
+  <status>Continuing</status>
+  <uniqueid type="animevietsub" default="true">1</uniqueid>
+</tvshow>
+`
+	if got := writeFile(t, show); got != want {
+		t.Errorf("tvshow.nfo =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestNewEpisode(t *testing.T) {
+	episode := extractor.Episode{Id: "98765", Title: `Tập 12 <"Cat & Mouse">`}
+	doc := NewEpisode(details, episode, 1, 12, 23*time.Minute+40*time.Second)
+	doc.DateAdded = "2024-01-02 03:04:05"
+
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<episodedetails>
+  <title>Tập 12 &lt;&#34;Cat &amp; Mouse&#34;&gt;</title>
+  <showtitle>Tom &amp; Jerry &lt;&#34;Special&#34;&gt;</showtitle>
+  <season>1</season>
+  <episode>12</episode>
+  <runtime>24</runtime>
+  <uniqueid type="animevietsub" default="true">98765</uniqueid>
+  <dateadded>2024-01-02 03:04:05</dateadded>
+</episodedetails>
+`
+	if got := writeFile(t, doc); got != want {
+		t.Errorf("episode nfo =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,278 @@
+package progressbar
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Multi owns a region at the bottom of the terminal and redraws several
+// progress bars in it. Text written to a Multi is printed above the bars,
+// so log output scrolls without tearing them.
+//
+// Outside of ModeBar nothing is redrawn: each bar reports on its own every
+// Interval and text written to the Multi is passed through unchanged, or
+// as one LogEvent per line in ModeJSON.
+type Multi struct {
+	mu       sync.Mutex
+	out      io.Writer
+	mode     Mode
+	interval time.Duration
+	bars     []*ProgressBar
+	lines    int
+	started  bool
+	partial  []byte
+}
+
+// MultiOptions configures a Multi. The Output, Mode and Interval of bars
+// added to the container are taken from here.
+type MultiOptions struct {
+	// Writer receiving the progress (default: os.Stdout)
+	Output io.Writer
+
+	// How progress is written (default: ModeAuto)
+	Mode Mode
+
+	// Time between two reports of a bar in ModePlain and ModeJSON
+	// (default: 5s)
+	Interval time.Duration
+}
+
+// NewMulti creates an empty multi-bar container.
+func NewMulti(opts MultiOptions) *Multi {
+	if opts.Output == nil {
+		opts.Output = os.Stdout
+	}
+	if opts.Interval == 0 {
+		opts.Interval = DefaultInterval
+	}
+	return &Multi{
+		out:      opts.Output,
+		mode:     resolveMode(opts.Mode, IsTerminal(opts.Output)),
+		interval: opts.Interval,
+	}
+}
+
+// Mode returns the resolved output mode of the container.
+func (m *Multi) Mode() Mode {
+	return m.mode
+}
+
+// Add creates a bar rendered by the container. Bars are drawn in the order
+// they were added.
+func (m *Multi) Add(opts Options) *ProgressBar {
+	opts.Output = m.out
+	opts.Mode = m.mode
+	opts.Interval = m.interval
+	bar := New(opts)
+	bar.parent = m
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bars = append(m.bars, bar)
+	m.redraw()
+	return bar
+}
+
+// Remove stops drawing bar. Its last state is printed once above the
+// remaining bars so finished work stays visible.
+func (m *Multi) Remove(bar *ProgressBar) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, b := range m.bars {
+		if b == bar {
+			m.bars = append(m.bars[:i], m.bars[i+1:]...)
+			if m.mode == ModeBar {
+				m.printAbove(bar.String() + "\n")
+			} else {
+				bar.report(m.out, true, bar.IsComplete())
+			}
+			return
+		}
+	}
+}
+
+// Start hides the cursor and draws the bars.
+func (m *Multi) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.mode != ModeBar {
+		return
+	}
+	if !m.started {
+		fmt.Fprint(m.out, "\x1b[?25l") // Hide cursor
+		m.started = true
+	}
+	m.redraw()
+}
+
+// Stop draws the final state of the bars, leaves them on screen and shows
+// the cursor again.
+func (m *Multi) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.mode != ModeBar {
+		for _, bar := range m.bars {
+			bar.report(m.out, true, bar.IsComplete())
+		}
+		if m.mode == ModeJSON && len(m.partial) > 0 {
+			writeLog(m.out, string(m.partial))
+			m.partial = nil
+		}
+		return
+	}
+
+	if len(m.partial) > 0 {
+		m.printAbove(string(m.partial) + "\n")
+		m.partial = nil
+	}
+	m.redraw()
+	if m.lines > 0 {
+		fmt.Fprintln(m.out)
+	}
+	m.lines = 0
+	if m.started {
+		fmt.Fprint(m.out, "\x1b[?25h") // Show cursor
+		m.started = false
+	}
+}
+
+// Write prints complete lines of p above the bars. It makes Multi usable
+// as the output of a logger while bars are active.
+func (m *Multi) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.mode == ModeJSON {
+		m.partial = append(m.partial, p...)
+		for {
+			end := bytes.IndexByte(m.partial, '\n')
+			if end < 0 {
+				break
+			}
+			writeLog(m.out, string(m.partial[:end]))
+			m.partial = m.partial[end+1:]
+		}
+		return len(p), nil
+	}
+	if !m.started {
+		return m.out.Write(p)
+	}
+
+	m.partial = append(m.partial, p...)
+	end := bytes.LastIndexByte(m.partial, '\n')
+	if end < 0 {
+		return len(p), nil
+	}
+
+	m.printAbove(string(m.partial[:end+1]))
+	m.partial = append(m.partial[:0], m.partial[end+1:]...)
+	return len(p), nil
+}
+
+// Println prints a line above the bars.
+func (m *Multi) Println(a ...any) {
+	fmt.Fprintln(m, a...)
+}
+
+// Printf prints formatted text above the bars.
+func (m *Multi) Printf(format string, a ...any) {
+	fmt.Fprintf(m, format, a...)
+}
+
+func (m *Multi) update(bar *ProgressBar, current int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bar.set(current)
+	m.refresh(bar)
+}
+
+func (m *Multi) add(bar *ProgressBar, delta int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bar.set(bar.current + delta)
+	m.refresh(bar)
+}
+
+// setBytes and friends only record state; the next update redraws it.
+func (m *Multi) setBytes(bar *ProgressBar, n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bar.setBytes(n)
+}
+
+func (m *Multi) addBytes(bar *ProgressBar, delta int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bar.setBytes(bar.bytes + delta)
+}
+
+func (m *Multi) setTotal(bar *ProgressBar, total int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bar.total = total
+}
+
+// clear moves the cursor to the first line of the region and erases it.
+func (m *Multi) clear(sb *strings.Builder) {
+	sb.WriteString("\r")
+	if m.lines > 1 {
+		fmt.Fprintf(sb, "\x1b[%dA", m.lines-1)
+	}
+	sb.WriteString("\x1b[J")
+	m.lines = 0
+}
+
+func (m *Multi) draw(sb *strings.Builder) {
+	for i, bar := range m.bars {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(bar.String())
+	}
+	m.lines = len(m.bars)
+}
+
+// refresh shows the change of bar: a redraw of the region in ModeBar, a
+// throttled report otherwise.
+func (m *Multi) refresh(bar *ProgressBar) {
+	if m.mode == ModeBar {
+		m.redraw()
+		return
+	}
+	bar.report(m.out, false, false)
+}
+
+func (m *Multi) redraw() {
+	if !m.started {
+		return
+	}
+	var sb strings.Builder
+	m.clear(&sb)
+	m.draw(&sb)
+	io.WriteString(m.out, sb.String())
+}
+
+func (m *Multi) printAbove(text string) {
+	if !m.started {
+		io.WriteString(m.out, text)
+		return
+	}
+	var sb strings.Builder
+	m.clear(&sb)
+	sb.WriteString(text)
+	m.draw(&sb)
+	io.WriteString(m.out, sb.String())
+}
@@ -0,0 +1,58 @@
+package progressbar
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMultiRedraw(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	var out bytes.Buffer
+	m := NewMulti(MultiOptions{Output: &out, Mode: ModeBar})
+
+	step := func(name, want string, do func()) {
+		t.Helper()
+		out.Reset()
+		do()
+		if out.String() != want {
+			t.Errorf("%s wrote\n%q\nwant\n%q", name, out.String(), want)
+		}
+	}
+
+	var a, b *ProgressBar
+	step("Start", "\x1b[?25l\r\x1b[J", m.Start)
+	step("Add", "\r\x1b[J"+"a ░░░░   0%", func() {
+		a = m.Add(Options{Total: 4, Width: 4, Label: "a"})
+	})
+	step("second Add", "\r\x1b[J"+"a ░░░░   0%\nb ░░░░   0%", func() {
+		b = m.Add(Options{Total: 2, Width: 4, Label: "b"})
+	})
+	// The whole region is cleared from its first line and drawn again.
+	step("Update", "\r\x1b[1A\x1b[J"+"a █░░░  25%\nb ░░░░   0%", func() {
+		a.Update(1)
+	})
+	step("Printf", "\r\x1b[1A\x1b[J"+"hello 1\n"+"a █░░░  25%\nb ░░░░   0%", func() {
+		m.Printf("hello %d\n", 1)
+	})
+	step("partial Printf", "", func() {
+		m.Printf("par")
+	})
+	step("rest of the line", "\r\x1b[1A\x1b[J"+"partial\n"+"a █░░░  25%\nb ░░░░   0%", func() {
+		m.Printf("tial\n")
+	})
+	step("Remove", "\r\x1b[1A\x1b[J"+"b ████ 100%\n"+"a █░░░  25%", func() {
+		b.Finish()
+		out.Reset()
+		m.Remove(b)
+	})
+	step("Stop", "\r\x1b[J"+"a █░░░  25%"+"\n\x1b[?25h", m.Stop)
+}
+
+func TestMultiWriteBeforeStart(t *testing.T) {
+	var out bytes.Buffer
+	m := NewMulti(MultiOptions{Output: &out, Mode: ModeBar})
+	m.Printf("no bars %s", "yet")
+	if out.String() != "no bars yet" {
+		t.Errorf("output = %q, want the text passed through", out.String())
+	}
+}
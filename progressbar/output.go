@@ -0,0 +1,174 @@
+package progressbar
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Mode selects how progress is written.
+type Mode int
+
+const (
+	// ModeAuto draws bars on terminals and falls back to ModePlain when the
+	// output is redirected or TERM is "dumb".
+	ModeAuto Mode = iota
+	// ModeBar redraws bars in place using carriage returns and escapes.
+	ModeBar
+	// ModePlain prints a plain status line every Interval.
+	ModePlain
+	// ModeJSON prints one JSON progress event per line every Interval.
+	ModeJSON
+	// ModeNone prints nothing.
+	ModeNone
+)
+
+// DefaultInterval is how often ModePlain and ModeJSON report progress.
+const DefaultInterval = 5 * time.Second
+
+// ParseMode converts a flag value ("auto", "bar", "plain", "json" or
+// "none") into a Mode.
+func ParseMode(name string) (Mode, error) {
+	switch strings.ToLower(name) {
+	case "", "auto":
+		return ModeAuto, nil
+	case "bar":
+		return ModeBar, nil
+	case "plain":
+		return ModePlain, nil
+	case "json":
+		return ModeJSON, nil
+	case "none":
+		return ModeNone, nil
+	}
+	return ModeAuto, fmt.Errorf("unknown progress mode %q (available: auto, bar, plain, json, none)", name)
+}
+
+// resolveMode turns ModeAuto into a concrete mode for an output that is or
+// is not a terminal.
+func resolveMode(mode Mode, terminal bool) Mode {
+	if mode != ModeAuto {
+		return mode
+	}
+	if !terminal || os.Getenv("TERM") == "dumb" {
+		return ModePlain
+	}
+	return ModeBar
+}
+
+// IsTerminal reports whether w is connected to a terminal.
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+// colorEnabled honors the NO_COLOR convention (https://no-color.org).
+func colorEnabled() bool {
+	_, noColor := os.LookupEnv("NO_COLOR")
+	return !noColor
+}
+
+// Event is the machine readable progress record written in ModeJSON.
+type Event struct {
+	// Event is "progress", telling these records apart from LogEvent.
+	Event   string  `json:"event"`
+	Label   string  `json:"label,omitempty"`
+	Current int     `json:"current"`
+	Total   int     `json:"total"`
+	Percent float64 `json:"percent"`
+	Bytes   int64   `json:"bytes,omitempty"`
+	Speed   float64 `json:"speed,omitempty"`
+	Elapsed float64 `json:"elapsed"`
+	ETA     float64 `json:"eta,omitempty"`
+	Done    bool    `json:"done,omitempty"`
+}
+
+func (pb *ProgressBar) event(done bool) Event {
+	e := Event{
+		Event:   "progress",
+		Label:   pb.name,
+		Current: pb.current,
+		Total:   pb.total,
+		Bytes:   pb.bytes,
+		Speed:   pb.speed(),
+		Elapsed: time.Since(pb.startTime).Seconds(),
+		Done:    done,
+	}
+	if pb.total > 0 {
+		e.Percent = float64(pb.current) * 100 / float64(pb.total)
+	}
+	if eta, ok := pb.etaDuration(); ok {
+		e.ETA = eta.Seconds()
+	}
+	return e
+}
+
+// LogEvent is written in ModeJSON for each line of text printed through a
+// Multi, so warnings do not break the one record per line output.
+type LogEvent struct {
+	Event   string `json:"event"` // always "log"
+	Message string `json:"message"`
+}
+
+// ansiEscape matches the color escapes of text meant for a terminal.
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// writeLog writes line as a LogEvent, without its color escapes.
+func writeLog(w io.Writer, line string) {
+	data, err := json.Marshal(LogEvent{Event: "log", Message: ansiEscape.ReplaceAllString(line, "")})
+	if err != nil {
+		return
+	}
+	w.Write(append(data, '\n'))
+}
+
+// plainLine renders the bar state without escapes, for logs and pipes.
+func (pb *ProgressBar) plainLine() string {
+	var sb strings.Builder
+	if pb.name != "" {
+		sb.WriteString(pb.name)
+		sb.WriteString(": ")
+	}
+
+	percentage := 0
+	if pb.total > 0 {
+		percentage = (pb.current * 100) / pb.total
+	}
+	fmt.Fprintf(&sb, "%d%% (%d/%d)", percentage, pb.current, pb.total)
+
+	// The stats are padded into columns for the bar; collapse the padding.
+	var stats strings.Builder
+	pb.writeStats(&stats)
+	for _, field := range strings.Fields(stats.String()) {
+		sb.WriteString(" ")
+		sb.WriteString(field)
+	}
+	return sb.String()
+}
+
+// report writes a plain or JSON progress record if the reporting interval
+// elapsed, or unconditionally when force is set.
+func (pb *ProgressBar) report(w io.Writer, force bool, done bool) {
+	now := time.Now()
+	if !force && !pb.lastReport.IsZero() && now.Sub(pb.lastReport) < pb.interval {
+		return
+	}
+	pb.lastReport = now
+
+	switch pb.mode {
+	case ModePlain:
+		fmt.Fprintln(w, pb.plainLine())
+	case ModeJSON:
+		data, err := json.Marshal(pb.event(done))
+		if err != nil {
+			return
+		}
+		w.Write(append(data, '\n'))
+	}
+}
@@ -0,0 +1,118 @@
+package progressbar
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveMode(t *testing.T) {
+	tests := []struct {
+		mode     Mode
+		terminal bool
+		term     string
+		want     Mode
+	}{
+		{ModeAuto, true, "xterm-256color", ModeBar},
+		{ModeAuto, false, "xterm-256color", ModePlain},
+		{ModeAuto, true, "dumb", ModePlain},
+		{ModeBar, false, "dumb", ModeBar},
+		{ModePlain, true, "xterm", ModePlain},
+		{ModeJSON, true, "xterm", ModeJSON},
+		{ModeNone, true, "xterm", ModeNone},
+	}
+	for _, tt := range tests {
+		t.Setenv("TERM", tt.term)
+		if got := resolveMode(tt.mode, tt.terminal); got != tt.want {
+			t.Errorf("resolveMode(%v, terminal=%v) with TERM=%s = %v, want %v", tt.mode, tt.terminal, tt.term, got, tt.want)
+		}
+	}
+}
+
+func TestResolveModeOfBuffer(t *testing.T) {
+	t.Setenv("TERM", "xterm")
+	if got := New(Options{Total: 1, Output: &bytes.Buffer{}}).mode; got != ModePlain {
+		t.Errorf("mode = %v for a buffer, want ModePlain", got)
+	}
+}
+
+func TestPlainReport(t *testing.T) {
+	var out bytes.Buffer
+	pb := New(Options{Total: 4, Label: "Ep 1", ShowSize: true, Output: &out, Mode: ModePlain, Interval: time.Hour})
+	pb.SetBytes(3 << 20)
+
+	pb.Update(1)
+	pb.Update(2) // within the interval
+	pb.Finish()
+
+	want := "Ep 1: 25% (1/4) 3.0 MiB\n" +
+		"Ep 1: 100% (4/4) 3.0 MiB\n"
+	if out.String() != want {
+		t.Errorf("output =\n%q\nwant\n%q", out.String(), want)
+	}
+}
+
+func TestJSONReport(t *testing.T) {
+	var out bytes.Buffer
+	pb := New(Options{Total: 4, Label: "Ep 1", Output: &out, Mode: ModeJSON, Interval: time.Hour})
+	pb.SetBytes(1000)
+
+	pb.Update(1)
+	pb.Update(2)
+	pb.Finish()
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d records, want 2:\n%s", len(lines), out.String())
+	}
+	var events []Event
+	for _, line := range lines {
+		var e Event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("record %q: %v", line, err)
+		}
+		events = append(events, e)
+	}
+
+	first, last := events[0], events[1]
+	if first.Event != "progress" || first.Label != "Ep 1" || first.Current != 1 || first.Total != 4 || first.Percent != 25 || first.Bytes != 1000 || first.Done {
+		t.Errorf("first record = %+v", first)
+	}
+	if last.Current != 4 || last.Percent != 100 || !last.Done || last.ETA != 0 {
+		t.Errorf("last record = %+v, want a complete one", last)
+	}
+}
+
+func TestMultiJSONLogs(t *testing.T) {
+	var out bytes.Buffer
+	m := NewMulti(MultiOptions{Output: &out, Mode: ModeJSON, Interval: time.Hour})
+	m.Start()
+	bar := m.Add(Options{Total: 2, Label: "Ep 1"})
+
+	m.Printf("\x1b[33mWarning\x1b[0m cover art could not be fetched: %v\n", "404")
+	bar.Update(1)
+	m.Printf("unterminated")
+	m.Stop()
+
+	var kinds []string
+	var messages []string
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("record %q: %v", line, err)
+		}
+		kinds = append(kinds, record["event"].(string))
+		if msg, ok := record["message"].(string); ok {
+			messages = append(messages, msg)
+		}
+	}
+
+	if want := []string{"log", "progress", "progress", "log"}; strings.Join(kinds, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %v, want %v", kinds, want)
+	}
+	if want := []string{"Warning cover art could not be fetched: 404", "unterminated"}; strings.Join(messages, "|") != strings.Join(want, "|") {
+		t.Errorf("messages = %q, want %q", messages, want)
+	}
+}
@@ -2,7 +2,11 @@ package progressbar
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 // Color represents an RGB color
@@ -17,8 +21,29 @@ type ProgressBar struct {
 	width          int
 	accentColor    Color
 	supports24Bit  bool
+	useColor       bool
 	cursorHidden   bool
 	showPercentage bool
+	name           string
+	label          string
+
+	out        io.Writer
+	mode       Mode
+	interval   time.Duration
+	lastReport time.Time
+
+	showSpeed   bool
+	showETA     bool
+	showSize    bool
+	showElapsed bool
+
+	bytes     int64
+	startTime time.Time
+	byteRate  rate
+	stepRate  rate
+
+	// parent is set for bars managed by a Multi, which owns the rendering
+	parent *Multi
 }
 
 // Options for configuring the progress bar
@@ -34,6 +59,33 @@ type Options struct {
 
 	// Show percentage (default: true)
 	ShowPercentage bool
+
+	// Label printed before the bar, padded or cut to LabelWidth
+	Label string
+
+	// Width of the label column in characters (default: length of Label)
+	LabelWidth int
+
+	// Show the smoothed transfer speed reported through SetBytes
+	ShowSpeed bool
+
+	// Show the estimated time until the bar completes
+	ShowETA bool
+
+	// Show the number of bytes reported through SetBytes
+	ShowSize bool
+
+	// Show the time since the bar was created
+	ShowElapsed bool
+
+	// Writer receiving the progress (default: os.Stdout)
+	Output io.Writer
+
+	// How progress is written (default: ModeAuto)
+	Mode Mode
+
+	// Time between two reports in ModePlain and ModeJSON (default: 5s)
+	Interval time.Duration
 }
 
 // DefaultAccentColor is a brownish/tan color (#847545)
@@ -44,6 +96,12 @@ func New(opts Options) *ProgressBar {
 	if opts.Width == 0 {
 		opts.Width = 40
 	}
+	if opts.Output == nil {
+		opts.Output = os.Stdout
+	}
+	if opts.Interval == 0 {
+		opts.Interval = DefaultInterval
+	}
 
 	accentColor := DefaultAccentColor
 	if opts.AccentColor != nil {
@@ -55,25 +113,45 @@ func New(opts Options) *ProgressBar {
 	supports24Bit := colorTerm == "truecolor" || colorTerm == "24bit"
 
 	return &ProgressBar{
+		name:           opts.Label,
+		label:          fitLabel(opts.Label, opts.LabelWidth),
 		total:          opts.Total,
 		current:        0,
 		width:          opts.Width,
 		accentColor:    accentColor,
 		supports24Bit:  supports24Bit,
+		useColor:       colorEnabled(),
 		cursorHidden:   false,
 		showPercentage: opts.ShowPercentage || opts.ShowPercentage == false && opts.Total > 0, // default true
+		showSpeed:      opts.ShowSpeed,
+		showETA:        opts.ShowETA,
+		showSize:       opts.ShowSize,
+		showElapsed:    opts.ShowElapsed,
+		out:            opts.Output,
+		mode:           resolveMode(opts.Mode, IsTerminal(opts.Output)),
+		interval:       opts.Interval,
+		startTime:      time.Now(),
 	}
 }
 
 func (pb *ProgressBar) Start() {
-	if !pb.cursorHidden {
-		fmt.Print("\x1b[?25l") // Hide cursor
+	if pb.mode == ModeBar && !pb.cursorHidden {
+		fmt.Fprint(pb.out, "\x1b[?25l") // Hide cursor
 		pb.cursorHidden = true
 	}
 	pb.Render()
 }
 
 func (pb *ProgressBar) Update(current int) {
+	if pb.parent != nil {
+		pb.parent.update(pb, current)
+		return
+	}
+	pb.set(current)
+	pb.Render()
+}
+
+func (pb *ProgressBar) set(current int) {
 	if current > pb.total {
 		current = pb.total
 	}
@@ -81,26 +159,82 @@ func (pb *ProgressBar) Update(current int) {
 		current = 0
 	}
 	pb.current = current
-	pb.Render()
+	pb.stepRate.observe(float64(current), time.Now())
+}
+
+// SetBytes records how many bytes the tracked work has transferred so far.
+// It feeds the size and speed fields and is shown on the next render.
+func (pb *ProgressBar) SetBytes(n int64) {
+	if pb.parent != nil {
+		pb.parent.setBytes(pb, n)
+		return
+	}
+	pb.setBytes(n)
+}
+
+// AddBytes adds delta to the transferred byte count.
+func (pb *ProgressBar) AddBytes(delta int64) {
+	if pb.parent != nil {
+		pb.parent.addBytes(pb, delta)
+		return
+	}
+	pb.setBytes(pb.bytes + delta)
+}
+
+func (pb *ProgressBar) setBytes(n int64) {
+	pb.bytes = n
+	pb.byteRate.observe(float64(n), time.Now())
 }
 
 func (pb *ProgressBar) Increment() {
-	pb.Update(pb.current + 1)
+	pb.Add(1)
 }
 func (pb *ProgressBar) Add(delta int) {
+	if pb.parent != nil {
+		pb.parent.add(pb, delta)
+		return
+	}
 	pb.Update(pb.current + delta)
 }
 
 func (pb *ProgressBar) Finish() {
-	pb.Update(pb.total)
-	if pb.cursorHidden {
-		fmt.Print("\x1b[?25h") // Show cursor
-		pb.cursorHidden = false
+	if pb.parent != nil {
+		pb.parent.update(pb, pb.total)
+		return
+	}
+	pb.set(pb.total)
+	pb.Stop()
+}
+
+// Stop ends the bar at its current value, e.g. after a failed download, and
+// restores the cursor.
+func (pb *ProgressBar) Stop() {
+	switch pb.mode {
+	case ModeBar:
+		pb.Render()
+		if pb.cursorHidden {
+			fmt.Fprint(pb.out, "\x1b[?25h") // Show cursor
+			pb.cursorHidden = false
+		}
+		fmt.Fprintln(pb.out) // Move to next line
+	case ModePlain, ModeJSON:
+		pb.report(pb.out, true, pb.IsComplete())
 	}
-	fmt.Println() // Move to next line
 }
 
 func (pb *ProgressBar) Render() {
+	switch pb.mode {
+	case ModeBar:
+		fmt.Fprint(pb.out, "\r"+pb.String())
+	case ModePlain, ModeJSON:
+		pb.report(pb.out, false, false)
+	}
+}
+
+// String renders the bar as a single line without moving the cursor.
+func (pb *ProgressBar) String() string {
+	var sb strings.Builder
+
 	percentage := 0
 	if pb.total > 0 {
 		percentage = (pb.current * 100) / pb.total
@@ -110,42 +244,105 @@ func (pb *ProgressBar) Render() {
 		numBlocks = (pb.current * pb.width) / pb.total
 	}
 
-	fmt.Print("\r")
+	if pb.label != "" {
+		sb.WriteString(pb.label)
+		sb.WriteString(" ")
+	}
 
-	if numBlocks > 0 {
+	if !pb.useColor {
+		sb.WriteString(strings.Repeat("█", numBlocks))
+		sb.WriteString(strings.Repeat("░", pb.width-numBlocks))
+	}
+
+	if pb.useColor && numBlocks > 0 {
 		if pb.supports24Bit {
-			fmt.Printf("\x1b[38;2;%d;%d;%dm", pb.accentColor.R, pb.accentColor.G, pb.accentColor.B)
+			fmt.Fprintf(&sb, "\x1b[38;2;%d;%d;%dm", pb.accentColor.R, pb.accentColor.G, pb.accentColor.B)
 		} else {
-			fmt.Print("\x1b[33m") // Yellow fallback
+			sb.WriteString("\x1b[33m") // Yellow fallback
 		}
 
-		for j := 0; j < numBlocks; j++ {
-			fmt.Print("█")
-		}
-		fmt.Print("\x1b[0m") // Reset color
+		sb.WriteString(strings.Repeat("█", numBlocks))
+		sb.WriteString("\x1b[0m") // Reset color
 	}
 
-	if numBlocks < pb.width {
+	if pb.useColor && numBlocks < pb.width {
 		if pb.supports24Bit {
 			darkR := pb.accentColor.R * 30 / 100
 			darkG := pb.accentColor.G * 30 / 100
 			darkB := pb.accentColor.B * 30 / 100
-			fmt.Printf("\x1b[38;2;%d;%d;%dm", darkR, darkG, darkB)
+			fmt.Fprintf(&sb, "\x1b[38;2;%d;%d;%dm", darkR, darkG, darkB)
 		} else {
-			fmt.Print("\x1b[90m") // Dark gray fallback
+			sb.WriteString("\x1b[90m") // Dark gray fallback
 		}
 
-		for j := numBlocks; j < pb.width; j++ {
-			fmt.Print("█")
-		}
-		fmt.Print("\x1b[0m") // Reset color
+		sb.WriteString(strings.Repeat("█", pb.width-numBlocks))
+		sb.WriteString("\x1b[0m") // Reset color
 	}
 
 	if pb.showPercentage {
-		fmt.Printf(" %3d%%", percentage)
+		fmt.Fprintf(&sb, " %3d%%", percentage)
 	}
+
+	pb.writeStats(&sb)
+	return sb.String()
 }
+
+// writeStats appends the enabled size, speed, elapsed and ETA columns.
+func (pb *ProgressBar) writeStats(sb *strings.Builder) {
+	if pb.showSize {
+		fmt.Fprintf(sb, " %10s", formatBytes(float64(pb.bytes)))
+	}
+	if pb.showSpeed {
+		fmt.Fprintf(sb, " %12s", formatBytes(pb.speed())+"/s")
+	}
+	if pb.showElapsed {
+		fmt.Fprintf(sb, " %s", formatDuration(time.Since(pb.startTime)))
+	}
+	if pb.showETA {
+		eta := "--:--"
+		if d, ok := pb.etaDuration(); ok {
+			eta = formatDuration(d)
+		}
+		fmt.Fprintf(sb, " ETA %s", eta)
+	}
+}
+
+// speed is the smoothed transfer rate in bytes per second, or the average
+// since start until the first sample is taken.
+func (pb *ProgressBar) speed() float64 {
+	if pb.byteRate.value > 0 {
+		return pb.byteRate.value
+	}
+	if elapsed := time.Since(pb.startTime).Seconds(); elapsed > 0 {
+		return float64(pb.bytes) / elapsed
+	}
+	return 0
+}
+
+// etaDuration estimates the remaining time from the smoothed progress rate,
+// falling back to the average rate since start until enough samples were
+// taken. It reports false while no estimate is possible.
+func (pb *ProgressBar) etaDuration() (time.Duration, bool) {
+	remaining := float64(pb.total - pb.current)
+	if remaining <= 0 {
+		return 0, true
+	}
+
+	perSecond := pb.stepRate.value
+	if perSecond <= 0 && pb.current > 0 {
+		perSecond = float64(pb.current) / time.Since(pb.startTime).Seconds()
+	}
+	if perSecond <= 0 {
+		return 0, false
+	}
+	return time.Duration(remaining / perSecond * float64(time.Second)), true
+}
+
 func (pb *ProgressBar) SetTotal(total int) {
+	if pb.parent != nil {
+		pb.parent.setTotal(pb, total)
+		return
+	}
 	pb.total = total
 }
 
@@ -159,3 +356,19 @@ func (pb *ProgressBar) GetTotal() int {
 func (pb *ProgressBar) IsComplete() bool {
 	return pb.current >= pb.total
 }
+
+// fitLabel pads or cuts label to width runes. A zero width keeps it as is.
+func fitLabel(label string, width int) string {
+	if width <= 0 {
+		return label
+	}
+	n := utf8.RuneCountInString(label)
+	if n <= width {
+		return label + strings.Repeat(" ", width-n)
+	}
+	runes := []rune(label)
+	if width == 1 {
+		return string(runes[:1])
+	}
+	return string(runes[:width-1]) + "…"
+}
@@ -0,0 +1,70 @@
+package progressbar
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// rateInterval is the minimum time between two rate samples; shorter
+	// bursts are accumulated into the next sample.
+	rateInterval = 500 * time.Millisecond
+	// rateWeight is the weight of a new sample in the moving average.
+	rateWeight = 0.3
+)
+
+// rate is an exponentially smoothed per-second rate of a growing counter.
+type rate struct {
+	value      float64
+	lastCount  float64
+	lastSample time.Time
+}
+
+func (r *rate) observe(count float64, now time.Time) {
+	if r.lastSample.IsZero() {
+		r.lastSample = now
+		r.lastCount = count
+		return
+	}
+
+	elapsed := now.Sub(r.lastSample)
+	if elapsed < rateInterval {
+		return
+	}
+
+	sample := (count - r.lastCount) / elapsed.Seconds()
+	if r.value == 0 {
+		r.value = sample
+	} else {
+		r.value = rateWeight*sample + (1-rateWeight)*r.value
+	}
+	r.lastSample = now
+	r.lastCount = count
+}
+
+// formatBytes renders n using binary units, e.g. "12.3 MiB".
+func formatBytes(n float64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%.0f B", n)
+	}
+	units := []string{"KiB", "MiB", "GiB", "TiB"}
+	i := -1
+	for n >= unit && i < len(units)-1 {
+		n /= unit
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", n, units[i])
+}
+
+// formatDuration renders d as mm:ss, or h:mm:ss for longer durations.
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	h := int(d / time.Hour)
+	m := int(d/time.Minute) % 60
+	s := int(d/time.Second) % 60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%02d:%02d", m, s)
+}
@@ -0,0 +1,93 @@
+package progressbar
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n    float64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{5 << 20, "5.0 MiB"},
+		{3 << 30, "3.0 GiB"},
+		{2048 << 40, "2048.0 TiB"},
+	}
+	for _, tt := range tests {
+		if got := formatBytes(tt.n); got != tt.want {
+			t.Errorf("formatBytes(%v) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "00:00"},
+		{1499 * time.Millisecond, "00:01"},
+		{59*time.Minute + 59*time.Second, "59:59"},
+		{time.Hour, "1:00:00"},
+		{25*time.Hour + 2*time.Minute + 3*time.Second, "25:02:03"},
+	}
+	for _, tt := range tests {
+		if got := formatDuration(tt.d); got != tt.want {
+			t.Errorf("formatDuration(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestRate(t *testing.T) {
+	start := time.Now()
+	var r rate
+	r.observe(0, start)
+	// Samples closer than rateInterval are folded into the next one.
+	r.observe(50, start.Add(100*time.Millisecond))
+	if r.value != 0 {
+		t.Fatalf("rate = %v after a short burst, want no sample yet", r.value)
+	}
+	r.observe(100, start.Add(time.Second))
+	if r.value != 100 {
+		t.Fatalf("rate = %v, want the first sample of 100/s", r.value)
+	}
+	r.observe(300, start.Add(2*time.Second))
+	if want := rateWeight*200 + (1-rateWeight)*100; math.Abs(r.value-want) > 1e-9 {
+		t.Errorf("rate = %v, want %v smoothed", r.value, want)
+	}
+}
+
+func TestETA(t *testing.T) {
+	tests := []struct {
+		name    string
+		current int
+		rate    float64
+		elapsed time.Duration
+		want    time.Duration
+		ok      bool
+	}{
+		{"smoothed rate", 4, 2, 0, 3 * time.Second, true},
+		{"average before the first sample", 5, 0, 10 * time.Second, 10 * time.Second, true},
+		{"no progress yet", 0, 0, 10 * time.Second, 0, false},
+		{"complete", 10, 0, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pb := New(Options{Total: 10, Mode: ModeNone})
+			pb.current = tt.current
+			pb.stepRate.value = tt.rate
+			pb.startTime = time.Now().Add(-tt.elapsed)
+
+			got, ok := pb.etaDuration()
+			if ok != tt.ok || got.Round(100*time.Millisecond) != tt.want {
+				t.Errorf("etaDuration() = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}